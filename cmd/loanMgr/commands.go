@@ -0,0 +1,882 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/adapters/repository"
+	"github.com/zapisanchez/loanMgr/internal/config"
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+	"github.com/zapisanchez/loanMgr/internal/core/services"
+)
+
+const usage = `Usage: loanMgr [global flags] [command] [flags]
+
+Without a command loanMgr starts the interactive menu.
+--user defaults to default_user and --format to output_format from the config file.
+
+Commands:
+  migrate          import every JSON file of the data directory into the SQLite database (--db)
+  serve            [--addr HOST:PORT] serve the JSON API over HTTP (default 127.0.0.1:8080)
+  compare          --offer name=NAME,amount=AMOUNT,rate=RATE,term=MONTHS|monthly=AMOUNT[,fee=AMOUNT][,monthly-fee=AMOUNT] (2 to 5 times)
+                   [--format table|json|csv|markdown] compare loan offers without storing them
+  user create      --user NAME
+  loan list        --user NAME [--archived] [--format table|json|csv|markdown]
+  loan create      --user NAME --name NAME --amount AMOUNT --rate RATE --monthly AMOUNT [--fee NAME=AMOUNT ...] [--monthly-fee NAME=AMOUNT ...]
+                   [--start YYYY-MM-DD] [--due-day DAY] [--term MONTHS]
+  loan calendar    --user NAME --loan ID [--start YYYY-MM-DD] [--due-day DAY] [--term MONTHS]
+  loan due         --user NAME [--format table|json|csv|markdown] next due dates, overdue installments and arrears
+  loan apr         --amount AMOUNT --rate RATE --monthly AMOUNT [--fee NAME=AMOUNT ...] [--monthly-fee NAME=AMOUNT ...] [--format table|json|csv|markdown]
+  loan fee         --user NAME --loan ID --name NAME --amount AMOUNT [--recurring]
+  loan penalty     --user NAME --loan ID [--late-fee AMOUNT] [--rate RATE] [--grace-days DAYS] | --remove
+  loan close       --user NAME --loan ID
+  loan write-off   --user NAME --loan ID
+  loan archive     --user NAME --loan ID
+  loan reopen      --user NAME --loan ID
+  loan plan        --user NAME --budget AMOUNT [--order ID,ID,...] [--format table|json|csv|markdown]
+  loan rate        --user NAME --loan ID --rate RATE [--date YYYY-MM-DD] [--monthly AMOUNT] [--format table|json|csv|markdown]
+  loan simulate    --user NAME --loan ID [--extra AMOUNT] [--lump YYYY-MM-DD=AMOUNT ...] [--format table|json|csv|markdown]
+  payment add      --user NAME --loan ID --amount AMOUNT [--desc TEXT] [--date YYYY-MM-DD] [--overpayment reject|cap|credit]
+  payment modify   --user NAME --loan ID --payment ID [--amount AMOUNT] [--desc TEXT] [--date YYYY-MM-DD]
+  payment remove   --user NAME --loan ID --payment ID  remove a payment, fee, adjustment or refund
+  payment history  --user NAME --loan ID [--format table|json|csv|markdown] the ledger of the loan with the balance after each entry
+  ledger add       --user NAME --loan ID --type fee|adjustment|refund --amount AMOUNT [--desc TEXT] [--date YYYY-MM-DD]
+                   an adjustment with a negative amount reduces the balance, a refund pays back the credit
+`
+
+// moneyFlag parses a flag value as an exact amount of money.
+type moneyFlag domain.Money
+
+func (m *moneyFlag) String() string {
+	return domain.Money(*m).String()
+}
+
+func (m *moneyFlag) Set(value string) error {
+	amount, err := domain.ParseMoney(value)
+	if err != nil {
+		return err
+	}
+	*m = moneyFlag(amount)
+	return nil
+}
+
+// lumpSumsFlag collects the repeated --lump flags as one-off extra payments.
+type lumpSumsFlag []domain.Prepayment
+
+func (l *lumpSumsFlag) String() string {
+	lumpSums := make([]string, len(*l))
+	for i, lumpSum := range *l {
+		lumpSums[i] = lumpSum.Date.Format(time.DateOnly) + "=" + lumpSum.Amount.String()
+	}
+	return strings.Join(lumpSums, ",")
+}
+
+func (l *lumpSumsFlag) Set(value string) error {
+	date, amount, found := strings.Cut(value, "=")
+	if !found {
+		return fmt.Errorf("invalid lump sum %q, use YYYY-MM-DD=AMOUNT", value)
+	}
+
+	parsedDate, err := time.ParseInLocation(time.DateOnly, date, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid lump sum date %q, use the YYYY-MM-DD format", date)
+	}
+
+	parsedAmount, err := domain.ParseMoney(amount)
+	if err != nil {
+		return err
+	}
+
+	*l = append(*l, domain.Prepayment{Date: parsedDate, Amount: parsedAmount})
+	return nil
+}
+
+// feesFlag collects the repeated fee flags as NAME=AMOUNT, all of them upfront or all recurring.
+type feesFlag struct {
+	fees      *[]domain.Fee
+	recurring bool
+}
+
+func (f feesFlag) String() string {
+	if f.fees == nil {
+		return ""
+	}
+
+	var fees []string
+	for _, fee := range *f.fees {
+		if fee.Recurring == f.recurring {
+			fees = append(fees, fee.Name+"="+fee.Amount.String())
+		}
+	}
+	return strings.Join(fees, ",")
+}
+
+func (f feesFlag) Set(value string) error {
+	name, amount, found := strings.Cut(value, "=")
+	if !found {
+		return fmt.Errorf("invalid fee %q, use NAME=AMOUNT", value)
+	}
+
+	parsedAmount, err := domain.ParseMoney(amount)
+	if err != nil {
+		return err
+	}
+
+	*f.fees = append(*f.fees, domain.Fee{Name: name, Amount: parsedAmount, Recurring: f.recurring})
+	return nil
+}
+
+// runCommand runs a non-interactive subcommand against the user service.
+func runCommand(cfg config.Config, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return migrateCommand(cfg)
+	case "serve":
+		return serveCommand(cfg, args[1:])
+	case "compare":
+		return compareCommand(cfg, args[1:])
+	}
+
+	if len(args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		return errors.New("missing command")
+	}
+
+	repo, err := newRepo(cfg)
+	if err != nil {
+		return fmt.Errorf("error initializing repository: %w", err)
+	}
+	defer closeRepo(repo)
+	srvcs := services.NewUserService(repo)
+
+	command := args[0] + " " + args[1]
+	flags := args[2:]
+
+	switch command {
+	case "user create":
+		err = userCreateCommand(srvcs, cfg, flags)
+	case "loan list":
+		err = loanListCommand(srvcs, cfg, flags)
+	case "loan create":
+		err = loanCreateCommand(srvcs, cfg, flags)
+	case "loan close":
+		err = loanStatusCommand(srvcs, cfg, command, domain.StatusClosed, flags)
+	case "loan write-off":
+		err = loanStatusCommand(srvcs, cfg, command, domain.StatusWrittenOff, flags)
+	case "loan archive":
+		err = loanStatusCommand(srvcs, cfg, command, domain.StatusArchived, flags)
+	case "loan reopen":
+		err = loanStatusCommand(srvcs, cfg, command, domain.StatusActive, flags)
+	case "loan calendar":
+		err = loanCalendarCommand(srvcs, cfg, flags)
+	case "loan due":
+		err = loanDueCommand(srvcs, cfg, flags)
+	case "loan apr":
+		err = loanAPRCommand(cfg, flags)
+	case "loan fee":
+		err = loanFeeCommand(srvcs, cfg, flags)
+	case "loan penalty":
+		err = loanPenaltyCommand(srvcs, cfg, flags)
+	case "loan plan":
+		err = loanPlanCommand(srvcs, cfg, flags)
+	case "loan rate":
+		err = loanRateCommand(srvcs, cfg, flags)
+	case "loan simulate":
+		err = loanSimulateCommand(srvcs, cfg, flags)
+	case "payment add":
+		err = paymentAddCommand(srvcs, cfg, flags)
+	case "payment modify":
+		err = paymentModifyCommand(srvcs, cfg, flags)
+	case "payment remove":
+		err = paymentRemoveCommand(srvcs, cfg, flags)
+	case "payment history":
+		err = paymentHistoryCommand(srvcs, cfg, flags)
+	case "ledger add":
+		err = ledgerAddCommand(srvcs, cfg, flags)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	return err
+}
+
+// migrateCommand imports the JSON files of the file store into the SQLite database.
+func migrateCommand(cfg config.Config) error {
+	count, err := repository.MigrateFilesToSQLite(cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d users imported into %s\n", count, cfg.DBPath)
+	return nil
+}
+
+// newFlagSet creates the flag set of a subcommand with the common --user flag.
+func newFlagSet(name string, cfg config.Config) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	userName := fs.String("user", cfg.DefaultUser, "name of the user")
+	return fs, userName
+}
+
+// lookupUser returns the user selected with --user, for the commands that change it.
+func lookupUser(srvcs *services.UserService, userName string) (*domain.User, error) {
+	if userName == "" {
+		return nil, errors.New("the --user flag is required")
+	}
+
+	user := srvcs.GetUser(userName)
+	if user == nil {
+		return nil, fmt.Errorf("%w: %q", services.ErrUserNotFound, userName)
+	}
+	return user, nil
+}
+
+// viewUser returns a copy of the user selected with --user with the charges of the late
+// installments of their loans up to date, for the commands that only show it. The charges
+// are not saved, the commands that change a loan post its own.
+func viewUser(srvcs *services.UserService, userName string) (*domain.User, error) {
+	user, err := lookupUser(srvcs, userName)
+	if err != nil {
+		return nil, err
+	}
+	return srvcs.UserWithPenalties(user.UserName, time.Now())
+}
+
+// lookupLoan returns the loan selected with --loan.
+func lookupLoan(user *domain.User, loanID string) (*domain.Loan, error) {
+	if loanID == "" {
+		return nil, errors.New("the --loan flag is required")
+	}
+
+	loan := user.GetLoan(loanID)
+	if loan == nil {
+		return nil, fmt.Errorf("%w: %q", services.ErrLoanNotFound, loanID)
+	}
+	return loan, nil
+}
+
+func userCreateCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	fs, userName := newFlagSet("user create", cfg)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *userName == "" {
+		return errors.New("the --user flag is required")
+	}
+
+	if _, err := srvcs.CreateUser(*userName); err != nil {
+		return err
+	}
+
+	fmt.Printf("User %s created\n", *userName)
+	return srvcs.Persist()
+}
+
+func loanListCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	fs, userName := newFlagSet("loan list", cfg)
+	formatName := fs.String("format", cfg.OutputFormat, "output format: table, json, csv or markdown")
+	archived := fs.Bool("archived", false, "list the archived loans instead of the current ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := services.ParseOutputFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	user, err := viewUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loans := user.CurrentLoans()
+	if *archived {
+		loans = user.ArchivedLoans()
+	}
+	return services.WriteLoans(os.Stdout, loans, format)
+}
+
+func loanCreateCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	var amount, monthly moneyFlag
+	var fees []domain.Fee
+
+	fs, userName := newFlagSet("loan create", cfg)
+	name := fs.String("name", "", "name of the loan")
+	rate := fs.Float64("rate", 0, "annual interest rate in percent")
+	fs.Var(&amount, "amount", "initial loan amount")
+	fs.Var(&monthly, "monthly", "monthly payment amount")
+	fs.Var(feesFlag{fees: &fees}, "fee", "fee charged once as NAME=AMOUNT, can be repeated")
+	fs.Var(feesFlag{fees: &fees, recurring: true}, "monthly-fee", "fee charged every month as NAME=AMOUNT, can be repeated")
+	start := fs.String("start", "", "date the loan was granted (YYYY-MM-DD or RFC3339), defaults to today")
+	dueDay := fs.Int("due-day", 0, "day of the month the installments are due, defaults to the day of the start date")
+	term := fs.Int("term", 0, "number of monthly installments, 0 when the loan runs until paid off")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := lookupUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	if *name == "" {
+		return errors.New("the --name flag is required")
+	}
+
+	startDate, err := domain.ParsePaymentDate(*start)
+	if err != nil {
+		return err
+	}
+	// Check the calendar first, so an invalid one does not leave the loan created without it
+	if err := domain.ValidateCalendar(startDate, *dueDay, *term); err != nil {
+		return err
+	}
+
+	loan, err := srvcs.CreateLoan(user.UserName, *name, domain.Money(amount), *rate, domain.Money(monthly), fees...)
+	if err != nil {
+		return err
+	}
+	if err := srvcs.SetLoanCalendar(user.UserName, loan.LoanID, startDate, *dueDay, *term); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loan %s created with ID %s\n", loan.LoanName, loan.LoanID)
+	return srvcs.Persist()
+}
+
+// loanCalendarCommand sets the start date, due day and term of a loan. The flags left out keep
+// their current value.
+func loanCalendarCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	fs, userName := newFlagSet("loan calendar", cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	start := fs.String("start", "", "date the loan was granted (YYYY-MM-DD or RFC3339), keeps the current one when left out")
+	dueDay := fs.Int("due-day", 0, "day of the month the installments are due, keeps the current one or defaults to the day of the start date")
+	term := fs.Int("term", -1, "number of monthly installments, 0 when the loan runs until paid off")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := lookupUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	startDate := ""
+	if *start != "" {
+		if startDate, err = domain.ParsePaymentDate(*start); err != nil {
+			return err
+		}
+	}
+
+	// Without --due-day keep the current one, if any
+	if *dueDay == 0 {
+		*dueDay = loan.DueDay
+	}
+	if *term < 0 {
+		*term = loan.Term
+	}
+
+	if err := srvcs.SetLoanCalendar(user.UserName, loan.LoanID, startDate, *dueDay, *term); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loan %s starts on %s, installments due on day %d", loan.LoanName, loan.StartTime().Format(time.DateOnly), loan.DueDay)
+	if loan.Term > 0 {
+		fmt.Printf(", the last one on %s", loan.MaturityDate().Format(time.DateOnly))
+	}
+	fmt.Println()
+	return srvcs.Persist()
+}
+
+// loanDueCommand shows the next due date, overdue installments and arrears of the active loans with a calendar.
+func loanDueCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	fs, userName := newFlagSet("loan due", cfg)
+	formatName := fs.String("format", cfg.OutputFormat, "output format: table, json, csv or markdown")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := services.ParseOutputFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	user, err := viewUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	return services.WriteDueStatuses(os.Stdout, services.DueStatuses(user.CurrentLoans(), time.Now()), format)
+}
+
+// loanAPRCommand shows the APR, effective rate and total cost of a loan offer without creating it.
+func loanAPRCommand(cfg config.Config, args []string) error {
+	var amount, monthly moneyFlag
+	var fees []domain.Fee
+
+	fs := flag.NewFlagSet("loan apr", flag.ContinueOnError)
+	rate := fs.Float64("rate", 0, "annual interest rate in percent")
+	formatName := fs.String("format", cfg.OutputFormat, "output format: table, json, csv or markdown")
+	fs.Var(&amount, "amount", "loan amount")
+	fs.Var(&monthly, "monthly", "monthly payment amount")
+	fs.Var(feesFlag{fees: &fees}, "fee", "fee charged once as NAME=AMOUNT, can be repeated")
+	fs.Var(feesFlag{fees: &fees, recurring: true}, "monthly-fee", "fee charged every month as NAME=AMOUNT, can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := services.ParseOutputFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	offer, err := services.NewOffer("Offer", domain.Money(amount), *rate, domain.Money(monthly), fees...)
+	if err != nil {
+		return err
+	}
+
+	cost, err := services.CalculateCost(offer)
+	if err != nil {
+		return err
+	}
+
+	return services.WriteLoanCosts(os.Stdout, []services.LoanCost{cost}, format)
+}
+
+// loanFeeCommand records a fee charged by the lender of an existing loan.
+func loanFeeCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	var amount moneyFlag
+
+	fs, userName := newFlagSet("loan fee", cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	name := fs.String("name", "", "name of the fee")
+	recurring := fs.Bool("recurring", false, "the fee is charged with every monthly installment")
+	fs.Var(&amount, "amount", "fee amount")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := lookupUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	fee := domain.Fee{Name: *name, Amount: domain.Money(amount), Recurring: *recurring}
+	if err := srvcs.AddFeeToLoan(user.UserName, loan.LoanID, fee); err != nil {
+		return err
+	}
+
+	fmt.Printf("Fee %s of %s added to loan %s\n", fee.Name, fee.Amount, loan.LoanID)
+	if apr, err := loan.APR(); err == nil {
+		fmt.Printf("The APR of loan %s is now %.2f%%\n", loan.LoanID, apr)
+	}
+	return srvcs.Persist()
+}
+
+// loanPenaltyCommand sets the late fee and penalty interest charged when an installment of a loan
+// is paid late, or removes them with --remove.
+func loanPenaltyCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	var lateFee moneyFlag
+
+	fs, userName := newFlagSet("loan penalty", cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	rate := fs.Float64("rate", 0, "annual penalty interest rate in percent on the unpaid part of a late installment")
+	graceDays := fs.Int("grace-days", 0, "days after the due date before an installment is late")
+	remove := fs.Bool("remove", false, "remove the penalties of the loan and reverse its charges")
+	fs.Var(&lateFee, "late-fee", "fee charged once for each late installment")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := lookupUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	var rule *domain.PenaltyRule
+	if !*remove {
+		rule = &domain.PenaltyRule{GraceDays: *graceDays, LateFee: domain.Money(lateFee), PenaltyRate: *rate}
+	}
+
+	if err := srvcs.SetPenaltyRule(user.UserName, loan.LoanID, rule); err != nil {
+		return err
+	}
+
+	if rule == nil {
+		fmt.Printf("Penalties removed from loan %s\n", loan.LoanID)
+	} else {
+		fmt.Printf("Installments of loan %s paid more than %d days late are charged a fee of %s and %.2f%% penalty interest\n",
+			loan.LoanID, rule.GraceDays, rule.LateFee, rule.PenaltyRate)
+	}
+	fmt.Printf("Late charges of loan %s: %s\n", loan.LoanID, loan.TotalCharges())
+	return srvcs.Persist()
+}
+
+// loanStatusCommand moves the loan selected with --loan to the given status.
+func loanStatusCommand(srvcs *services.UserService, cfg config.Config, name string, status domain.LoanStatus, args []string) error {
+	fs, userName := newFlagSet(name, cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := lookupUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	if err := srvcs.ChangeLoanStatus(user.UserName, loan.LoanID, status); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loan %s is now %s\n", loan.LoanID, loan.GetStatus())
+	return srvcs.Persist()
+}
+
+// loanPlanCommand compares the snowball, avalanche and custom order plans to pay off the active loans with a monthly budget.
+func loanPlanCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	var budget moneyFlag
+
+	fs, userName := newFlagSet("loan plan", cfg)
+	order := fs.String("order", "", "comma separated loan IDs in the order to pay them off, adds a custom plan")
+	formatName := fs.String("format", cfg.OutputFormat, "output format: table, json, csv or markdown")
+	fs.Var(&budget, "budget", "total amount paid every month across all loans")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := services.ParseOutputFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	user, err := viewUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	if budget <= 0 {
+		return errors.New("the --budget flag is required")
+	}
+
+	var loanIDs []string
+	for _, loanID := range strings.Split(*order, ",") {
+		if loanID = strings.TrimSpace(loanID); loanID != "" {
+			loanIDs = append(loanIDs, loanID)
+		}
+	}
+
+	plans, err := services.PlanStrategies(user.CurrentLoans(), domain.Money(budget), loanIDs, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return services.WritePayoffPlans(os.Stdout, plans, format)
+}
+
+// loanRateCommand records a change of the interest rate of a loan and shows how it changes the payoff.
+func loanRateCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	var monthly moneyFlag
+
+	fs, userName := newFlagSet("loan rate", cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	rate := fs.Float64("rate", -1, "new annual interest rate in percent")
+	date := fs.String("date", "", "date the new rate applies from (YYYY-MM-DD or RFC3339), defaults to now")
+	formatName := fs.String("format", cfg.OutputFormat, "output format: table, json, csv or markdown")
+	fs.Var(&monthly, "monthly", "new monthly payment, keeps the current one when left out")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := services.ParseOutputFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	user, err := lookupUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	if *rate < 0 {
+		return errors.New("the --rate flag is required")
+	}
+
+	effectiveDate, err := domain.ParsePaymentDate(*date)
+	if err != nil {
+		return err
+	}
+
+	change := domain.RateChange{EffectiveDate: effectiveDate, Rate: *rate, MonthlyPayment: domain.Money(monthly)}
+	impact, err := srvcs.ChangeLoanRate(user.UserName, loan.LoanID, change)
+	if err != nil {
+		return err
+	}
+
+	if err := services.WriteRateChangeImpact(os.Stdout, impact, format); err != nil {
+		return err
+	}
+	return srvcs.Persist()
+}
+
+// loanSimulateCommand shows the payoff date and interest saved by extra payments, without changing the loan.
+func loanSimulateCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	var extra moneyFlag
+	var lumpSums lumpSumsFlag
+
+	fs, userName := newFlagSet("loan simulate", cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	formatName := fs.String("format", cfg.OutputFormat, "output format: table, json, csv or markdown")
+	fs.Var(&extra, "extra", "amount paid every month on top of the monthly payment")
+	fs.Var(&lumpSums, "lump", "one-off payment as YYYY-MM-DD=AMOUNT, can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := services.ParseOutputFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	user, err := viewUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	plan := services.PrepaymentPlan{MonthlyExtra: domain.Money(extra), LumpSums: lumpSums}
+	simulation, err := services.SimulatePrepayments(*loan, plan, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return services.WriteSimulation(os.Stdout, simulation, format)
+}
+
+func paymentAddCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	var amount moneyFlag
+
+	fs, userName := newFlagSet("payment add", cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	description := fs.String("desc", "", "description of the payment")
+	date := fs.String("date", "", "date of the payment (YYYY-MM-DD or RFC3339), defaults to now")
+	overpayment := fs.String("overpayment", string(domain.OverpaymentReject), "payment above the payoff amount: reject, cap it or record the excess as credit")
+	fs.Var(&amount, "amount", "payment amount")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mode, err := domain.ParseOverpaymentMode(*overpayment)
+	if err != nil {
+		return err
+	}
+
+	user, err := lookupUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	paymentDate, err := domain.ParsePaymentDate(*date)
+	if err != nil {
+		return err
+	}
+
+	payment := domain.Transaction{Amount: domain.Money(amount), Description: *description, DateTime: paymentDate}
+	// The payment may have been capped, the stored one has the final amount
+	added, err := srvcs.AddPaymentToLoan(user.UserName, loan.LoanID, payment, mode)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Payment of %s added to loan %s\n", added.Amount, loan.LoanID)
+	if credit := loan.Credit(); credit > 0 {
+		fmt.Printf("Loan %s is paid off with a credit of %s to refund\n", loan.LoanID, credit)
+	}
+	return srvcs.Persist()
+}
+
+// paymentModifyCommand corrects the amount, description or date of a payment. The flags left out
+// keep their current value.
+func paymentModifyCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	var amount moneyFlag
+
+	fs, userName := newFlagSet("payment modify", cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	paymentID := fs.String("payment", "", "ID of the payment")
+	description := fs.String("desc", "", "description of the payment, keeps the current one when left out")
+	date := fs.String("date", "", "date of the payment (YYYY-MM-DD or RFC3339), keeps the current one when left out")
+	fs.Var(&amount, "amount", "payment amount, keeps the current one when left out")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := lookupUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	payment := loan.GetPayment(*paymentID)
+	if payment == nil {
+		return fmt.Errorf("%w: %q in loan %s", services.ErrPaymentNotFound, *paymentID, loan.LoanID)
+	}
+
+	newAmount := cmp.Or(domain.Money(amount), payment.Amount)
+	newDescription := cmp.Or(*description, payment.Description)
+	newDate := ""
+	if *date != "" {
+		if newDate, err = domain.ParsePaymentDate(*date); err != nil {
+			return err
+		}
+	}
+
+	if err := srvcs.ModifyPaymentFromLoan(user.UserName, loan.LoanID, *paymentID, newAmount, newDescription, newDate); err != nil {
+		return err
+	}
+
+	fmt.Printf("Payment %s of loan %s modified, late charges: %s\n", *paymentID, loan.LoanID, loan.TotalCharges())
+	return srvcs.Persist()
+}
+
+// paymentRemoveCommand removes a payment, fee, adjustment or refund entered by mistake.
+func paymentRemoveCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	fs, userName := newFlagSet("payment remove", cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	paymentID := fs.String("payment", "", "ID of the payment")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := lookupUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	if err := srvcs.RemovePaymentFromLoan(user.UserName, loan.LoanID, *paymentID); err != nil {
+		return err
+	}
+
+	fmt.Printf("Payment %s removed from loan %s, remaining balance: %s\n", *paymentID, loan.LoanID, loan.OutstandingBalance())
+	return srvcs.Persist()
+}
+
+func paymentHistoryCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	fs, userName := newFlagSet("payment history", cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	formatName := fs.String("format", cfg.OutputFormat, "output format: table, json, csv or markdown")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := services.ParseOutputFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	user, err := viewUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	return services.WritePaymentHistory(os.Stdout, *loan, format)
+}
+
+// ledgerAddCommand adds a fee, an adjustment or a refund to the ledger of a loan.
+func ledgerAddCommand(srvcs *services.UserService, cfg config.Config, args []string) error {
+	var amount moneyFlag
+
+	fs, userName := newFlagSet("ledger add", cfg)
+	loanID := fs.String("loan", "", "ID of the loan")
+	typeName := fs.String("type", "", "type of the entry: fee, adjustment or refund")
+	description := fs.String("desc", "", "description of the entry")
+	date := fs.String("date", "", "date of the entry (YYYY-MM-DD or RFC3339), defaults to now")
+	fs.Var(&amount, "amount", "amount of the entry, negative for an adjustment that reduces the balance")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	transactionType, err := domain.ParseTransactionType(*typeName)
+	if err != nil {
+		return err
+	}
+
+	user, err := lookupUser(srvcs, *userName)
+	if err != nil {
+		return err
+	}
+
+	loan, err := lookupLoan(user, *loanID)
+	if err != nil {
+		return err
+	}
+
+	entryDate, err := domain.ParsePaymentDate(*date)
+	if err != nil {
+		return err
+	}
+
+	transaction := domain.Transaction{Type: transactionType, Amount: domain.Money(amount), Description: *description, DateTime: entryDate}
+	added, err := srvcs.AddTransactionToLoan(user.UserName, loan.LoanID, transaction)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Ledger entry %s (%s) of %s added to loan %s, remaining balance: %s\n", added.ID, added.Type, added.Amount, loan.LoanID, loan.OutstandingBalance())
+	return srvcs.Persist()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zapisanchez/loanMgr/internal/config"
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+	"github.com/zapisanchez/loanMgr/internal/core/services"
+)
+
+// offersFlag collects the repeated --offer flags. Each one is a comma separated list of
+// key=value pairs: name, amount, rate, term or monthly, and fee or monthly-fee, which can be repeated.
+type offersFlag []services.Offer
+
+func (o *offersFlag) String() string {
+	names := make([]string, len(*o))
+	for i, offer := range *o {
+		names[i] = offer.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func (o *offersFlag) Set(value string) error {
+	offer := services.Offer{Name: fmt.Sprintf("Offer %d", len(*o)+1)}
+
+	for _, pair := range strings.Split(value, ",") {
+		key, field, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return fmt.Errorf("invalid offer field %q, use key=value", pair)
+		}
+
+		var err error
+		switch key {
+		case "name":
+			offer.Name = field
+		case "amount":
+			offer.Amount, err = domain.ParseMoney(field)
+		case "rate":
+			offer.Interest, err = strconv.ParseFloat(strings.TrimSuffix(field, "%"), 64)
+		case "term":
+			offer.Term, err = strconv.Atoi(field)
+		case "monthly":
+			offer.MonthlyPayment, err = domain.ParseMoney(field)
+		case "fee", "monthly-fee":
+			var amount domain.Money
+			amount, err = domain.ParseMoney(field)
+			fee := domain.Fee{Name: "Upfront fee", Amount: amount}
+			if key == "monthly-fee" {
+				fee = domain.Fee{Name: "Monthly fee", Amount: amount, Recurring: true}
+			}
+			offer.Fees = append(offer.Fees, fee)
+		default:
+			return fmt.Errorf("unknown offer field %q, use name, amount, rate, term, monthly, fee or monthly-fee", key)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid offer %s %q: %w", key, field, err)
+		}
+	}
+
+	*o = append(*o, offer)
+	return nil
+}
+
+// compareCommand shows the cost of several loan offers side by side. It does not use the stored data.
+func compareCommand(cfg config.Config, args []string) error {
+	var offers offersFlag
+
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	formatName := fs.String("format", cfg.OutputFormat, "output format: table, json, csv or markdown")
+	fs.Var(&offers, "offer", "offer as name=NAME,amount=AMOUNT,rate=RATE,term=MONTHS|monthly=AMOUNT[,fee=AMOUNT][,monthly-fee=AMOUNT], repeat it for each offer")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := services.ParseOutputFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	comparison, err := services.CompareOffers(offers)
+	if err != nil {
+		return err
+	}
+
+	return services.WriteOfferComparison(os.Stdout, comparison, format)
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/zapisanchez/loanMgr/internal/adapters/input"
 	"github.com/zapisanchez/loanMgr/internal/adapters/repository"
+	"github.com/zapisanchez/loanMgr/internal/config"
 	"github.com/zapisanchez/loanMgr/internal/core/domain"
 	"github.com/zapisanchez/loanMgr/internal/core/services"
 
@@ -16,19 +19,65 @@ import (
 )
 
 func main() {
+	flags := config.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		fmt.Fprintln(flag.CommandLine.Output(), "\nGlobal flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Subcommands are meant for scripts: keep stdout for the results and only log warnings to stderr
+	interactive := flag.NArg() == 0
+	if interactive {
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
+	} else {
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr}).Level(zerolog.WarnLevel)
+	}
+
+	cfg, err := flags.Load()
+	if err != nil {
+		log.Error().Err(err).Msg("Error loading configuration")
+		os.Exit(1)
+	}
+
+	if cfg.LogLevel != "" {
+		level, err := zerolog.ParseLevel(cfg.LogLevel)
+		if err != nil {
+			log.Error().Err(err).Msg("Invalid log level")
+			os.Exit(1)
+		}
+		log.Logger = log.Logger.Level(level)
+	}
+	services.SetCurrency(cfg.Currency)
+
+	if !interactive {
+		err := runCommand(cfg, flag.Args())
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Command failed")
+			os.Exit(1)
+		}
+		return
+	}
+
 	input.ClearScreen()
-	// Configure zerolog for output
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
 
-	repo, err := repository.NewFileRepo()
+	repo, err := newRepo(cfg)
 	if err != nil {
 		log.Error().Err(err).Msg("Error initializing repository")
 		return
 	}
+	defer closeRepo(repo)
 	srvcs := services.NewUserService(repo)
 
-	// Get the username
-	userName := input.GetUserName()
+	// Get the username, unless a default user is configured
+	userName := cfg.DefaultUser
+	if userName == "" {
+		userName = input.GetUserName()
+	}
 	log.Info().Str("username", userName).Msg("User entered")
 
 	var selectedUser *domain.User
@@ -55,35 +104,87 @@ func main() {
 
 	input.ClearScreen()
 
+	// Post the charges of the installments that became late since the last run
+	if posted, reversed, err := srvcs.ApplyPenalties(userName, time.Now()); err != nil {
+		log.Error().Err(err).Msg("Error applying penalties")
+	} else if posted > 0 || reversed > 0 {
+		log.Info().Int("posted", posted).Int("reversed", reversed).Msg("Late payment charges updated")
+	}
+
+	// Remind the installments overdue or due soon before anything else
+	services.PrintDueBanner(selectedUser.Loans)
+
 	// Main menu loop
 	for {
 		fmt.Println("Select an option:")
 		fmt.Println("======= Loans =======")
 		fmt.Println("1) Show loans")
 		fmt.Println("2) Create a new loan")
+		fmt.Println("3) View amortization schedule")
+		fmt.Println("4) Close, write off or archive a loan")
+		fmt.Println("5) Show archived loans")
+		fmt.Println("6) Reopen a loan")
+		fmt.Println("7) Simulate extra payments")
+		fmt.Println("8) Plan the payoff of all loans (snowball / avalanche)")
+		fmt.Println("9) Record an interest rate change")
+		fmt.Println("10) APR calculator")
+		fmt.Println("11) Compare loan offers")
+		fmt.Println("12) Payment calendar (due dates and arrears)")
+		fmt.Println("13) Set the start date, due day and term of a loan")
+		fmt.Println("14) Set the late fee and penalty interest of a loan")
 
 		fmt.Println()
 		fmt.Println("======= Payments =======")
-		fmt.Println("3) Add a payment")
-		fmt.Println("4) Modify a payment")
-		fmt.Println("5) View payment history")
+		fmt.Println("15) Add a payment")
+		fmt.Println("16) Modify a payment")
+		fmt.Println("17) Remove a payment")
+		fmt.Println("18) View payment history")
 
 		fmt.Println()
-		fmt.Println("6) Exit")
+		fmt.Println("19) Exit")
 		choice := input.GetUserChoice()
 
 		switch choice {
 		case "1":
-			services.PrintAllLoans(selectedUser.Loans) // A function to print all loans
+			services.PrintAllLoans(selectedUser.CurrentLoans()) // A function to print all loans
 		case "2":
 			createNewLoan(selectedUser, srvcs) // Function to create a new loan
 		case "3":
-			addPaymentToLoan(selectedUser, srvcs) // Function to add payment to an existing loan
+			viewAmortizationSchedule(selectedUser) // Function to view the amortization schedule of a loan
 		case "4":
-			modifyPaymentFromLoan(selectedUser, srvcs) // New function to modify a payment
+			changeLoanStatus(selectedUser, srvcs)
 		case "5":
-			viewPaymentHistory(selectedUser) // New function to view payment history
+			services.PrintAllLoans(selectedUser.ArchivedLoans())
 		case "6":
+			reopenLoan(selectedUser, srvcs)
+		case "7":
+			simulatePrepayments(selectedUser)
+		case "8":
+			planPayoff(selectedUser)
+		case "9":
+			changeLoanRate(selectedUser, srvcs)
+		case "10":
+			calculateAPR()
+		case "11":
+			compareOffers()
+		case "12":
+			services.PrintDueStatuses(services.DueStatuses(selectedUser.CurrentLoans(), time.Now()))
+		case "13":
+			setLoanCalendar(selectedUser, srvcs)
+		case "14":
+			setLoanPenalty(selectedUser, srvcs)
+		case "15":
+			addPaymentToLoan(selectedUser, srvcs) // Function to add payment to an existing loan
+		case "16":
+			modifyPaymentFromLoan(selectedUser, srvcs) // New function to modify a payment
+		case "17":
+			removePaymentFromLoan(selectedUser, srvcs)
+		case "18":
+			viewPaymentHistory(selectedUser) // New function to view payment history
+		case "19":
+			if err := srvcs.Persist(); err != nil {
+				log.Error().Err(err).Msg("Error saving user data")
+			}
 			log.Info().Msg("Exiting the program.")
 			return // Exit the program
 		default:
@@ -92,6 +193,26 @@ func main() {
 	}
 }
 
+// newRepo opens the configured storage backend.
+func newRepo(cfg config.Config) (services.UserRepo, error) {
+	switch cfg.Store {
+	case "file":
+		return repository.NewFileRepo(cfg)
+	case "sqlite":
+		return repository.NewSQLiteRepo(cfg)
+	}
+	return nil, fmt.Errorf("unknown store %q, use file or sqlite", cfg.Store)
+}
+
+// closeRepo releases the resources held by the repository, if any.
+func closeRepo(repo services.UserRepo) {
+	if closer, ok := repo.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing repository")
+		}
+	}
+}
+
 func createNewLoan(user *domain.User, srvc *services.UserService) {
 	log.Info().Msg("Creating a new loan.")
 
@@ -100,30 +221,319 @@ func createNewLoan(user *domain.User, srvc *services.UserService) {
 	initialLoan := input.GetInitialLoanAmount()
 	monthlyPayment := input.GetMonthlyPaymentAmount()
 	interest := input.GetInterestRate()
+	fees := input.GetFees()
+	startDate := input.GetStartDate(time.Now())
+	dueDay := input.GetDueDay(dueDayOf(startDate))
+	term := input.GetLoanTerm()
+
+	// Create the new loan, the service gives it a unique LoanID
+	loan, err := srvc.CreateLoan(user.UserName, loanName, initialLoan, interest, monthlyPayment, fees...)
+	if err != nil {
+		log.Error().Err(err).Msg("Error Creating Loan")
+		return
+	}
+	log.Info().Str("loan_id", loan.LoanID).Msg("New loan created")
+
+	if err := srvc.SetLoanCalendar(user.UserName, loan.LoanID, startDate, dueDay, term); err != nil {
+		log.Error().Err(err).Msg("Error setting the payment calendar of the loan")
+	}
+}
+
+// setLoanCalendar sets the start date, due day and term of a loan, so its installments can be tracked.
+func setLoanCalendar(user *domain.User, srvc *services.UserService) {
+	var loans []domain.Loan
+	for _, loan := range user.Loans {
+		if loan.GetStatus().IsOpen() {
+			loans = append(loans, loan)
+		}
+	}
+
+	if len(loans) == 0 {
+		log.Warn().Msg("No open loans available to set their payment calendar.")
+		return
+	}
+
+	loanID := input.GetLoanSelection(loans)
+
+	// If the user selects "exit", return to the main menu
+	if loanID == "" {
+		return
+	}
+
+	// Empty answers keep the current start date and due day
+	selectedLoan := user.GetLoan(loanID)
+	start := selectedLoan.StartTime()
+	if start.IsZero() {
+		start = time.Now()
+	}
+	startDate := input.GetStartDate(start)
+
+	dueDay := selectedLoan.DueDay
+	if dueDay == 0 {
+		dueDay = dueDayOf(startDate)
+	}
+	dueDay = input.GetDueDay(dueDay)
+	term := input.GetLoanTerm()
+
+	if err := srvc.SetLoanCalendar(user.UserName, loanID, startDate, dueDay, term); err != nil {
+		log.Error().Err(err).Msg("Error setting the payment calendar")
+		return
+	}
+	log.Info().Str("loan_id", loanID).Msg("Payment calendar set")
+}
+
+// setLoanPenalty sets the late fee and penalty interest charged when an installment of a loan is
+// paid late. Leaving both at zero removes them.
+func setLoanPenalty(user *domain.User, srvc *services.UserService) {
+	var loans []domain.Loan
+	for _, loan := range user.Loans {
+		if loan.GetStatus().IsOpen() {
+			loans = append(loans, loan)
+		}
+	}
 
-	// Generate a unique LoanID
-	loanID := generateUniqueLoanID(*user)
+	if len(loans) == 0 {
+		log.Warn().Msg("No open loans available to set their penalties.")
+		return
+	}
+
+	loanID := input.GetLoanSelection(loans)
+
+	// If the user selects "exit", return to the main menu
+	if loanID == "" {
+		return
+	}
 
-	// Create the new loan
-	loan := domain.Loan{
-		LoanID:         loanID,
-		LoanName:       loanName,
-		Amount:         initialLoan,
-		Interest:       interest,
-		MonthlyPayment: monthlyPayment,
+	if !user.GetLoan(loanID).HasCalendar() {
+		log.Warn().Msg("Set the start date and due day of the loan first, penalties are charged on its late installments.")
 	}
-	err := srvc.AddLoanToUser(user.UserName, loan)
+
+	rule := &domain.PenaltyRule{
+		GraceDays:   input.GetGraceDays(),
+		LateFee:     input.GetLateFee(),
+		PenaltyRate: input.GetPenaltyRate(),
+	}
+	if rule.LateFee == 0 && rule.PenaltyRate == 0 {
+		rule = nil
+	}
+
+	if err := srvc.SetPenaltyRule(user.UserName, loanID, rule); err != nil {
+		log.Error().Err(err).Msg("Error setting the penalties")
+		return
+	}
+
+	loan := user.GetLoan(loanID)
+	log.Info().Str("loan_id", loanID).Stringer("charges", loan.TotalCharges()).Msg("Penalties set")
+}
+
+// dueDayOf returns the day of the month of a date, the default due day of a loan granted that day.
+func dueDayOf(date string) int {
+	t, err := time.Parse(time.RFC3339, date)
 	if err != nil {
-		log.Error().Err(err).Msg("Error Creating Loan")
+		return time.Now().Day()
+	}
+	return t.Day()
+}
+
+// changeLoanStatus closes, writes off or archives a loan that is not archived yet.
+func changeLoanStatus(user *domain.User, srvc *services.UserService) {
+	loans := user.CurrentLoans()
+	if len(loans) == 0 {
+		log.Warn().Msg("No loans available to change their status.")
+		return
+	}
+
+	loanID := input.GetLoanSelection(loans)
+
+	// If the user selects "exit", return to the main menu
+	if loanID == "" {
+		return
+	}
+
+	selectedLoan := user.GetLoan(loanID)
+	fmt.Printf("Loan %s is %s.\n", selectedLoan.LoanName, selectedLoan.GetStatus())
+
+	status := input.GetStatusSelection(selectedLoan.NextStatuses())
+	if status == "" {
+		return
+	}
+
+	if err := srvc.ChangeLoanStatus(user.UserName, loanID, status); err != nil {
+		log.Error().Err(err).Msg("Error changing loan status")
+		return
+	}
+	log.Info().Str("loan_id", loanID).Str("status", string(selectedLoan.GetStatus())).Msg("Loan status changed")
+}
+
+// reopenLoan makes a closed, written off or archived loan active again.
+func reopenLoan(user *domain.User, srvc *services.UserService) {
+	var loans []domain.Loan
+	for _, loan := range user.Loans {
+		if !loan.GetStatus().IsOpen() {
+			loans = append(loans, loan)
+		}
+	}
+
+	if len(loans) == 0 {
+		log.Warn().Msg("No closed, written off or archived loans to reopen.")
+		return
+	}
+
+	loanID := input.GetLoanSelection(loans)
+
+	// If the user selects "exit", return to the main menu
+	if loanID == "" {
+		return
+	}
+
+	if err := srvc.ReopenLoan(user.UserName, loanID); err != nil {
+		log.Error().Err(err).Msg("Error reopening loan")
+		return
+	}
+	log.Info().Str("loan_id", loanID).Msg("Loan reopened")
+}
+
+// simulatePrepayments shows how much time and interest extra payments would save on a loan.
+// The loan is not changed.
+func simulatePrepayments(user *domain.User) {
+	loans := user.CurrentLoans()
+	if len(loans) == 0 {
+		log.Warn().Msg("No loans available to simulate extra payments.")
+		return
+	}
+
+	loanID := input.GetLoanSelection(loans)
+
+	// If the user selects "exit", return to the main menu
+	if loanID == "" {
+		return
+	}
+
+	plan := services.PrepaymentPlan{
+		MonthlyExtra: input.GetExtraMonthlyPayment(),
+		LumpSums:     input.GetLumpSums(),
+	}
+
+	simulation, err := services.SimulatePrepayments(*user.GetLoan(loanID), plan, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Error simulating extra payments")
+		return
+	}
+
+	services.PrintSimulation(simulation)
+}
+
+// planPayoff compares the snowball, avalanche and custom order plans to pay off every active loan
+// with a monthly budget.
+func planPayoff(user *domain.User) {
+	loans := user.CurrentLoans()
+	if len(loans) == 0 {
+		log.Warn().Msg("No loans available to plan their payoff.")
+		return
+	}
+
+	budget := input.GetMonthlyBudget()
+	order := input.GetLoanOrder()
+
+	plans, err := services.PlanStrategies(loans, budget, order, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Error planning the payoff")
+		return
+	}
+
+	services.PrintPayoffPlans(plans)
+}
+
+// calculateAPR shows the APR, effective rate and total cost of a loan offer without creating it.
+func calculateAPR() {
+	initialLoan := input.GetInitialLoanAmount()
+	monthlyPayment := input.GetMonthlyPaymentAmount()
+	interest := input.GetInterestRate()
+	fees := input.GetFees()
+
+	offer, err := services.NewOffer("Offer", initialLoan, interest, monthlyPayment, fees...)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid loan terms")
+		return
+	}
+
+	cost, err := services.CalculateCost(offer)
+	if err != nil {
+		log.Error().Err(err).Msg("Error calculating the APR")
+		return
+	}
+
+	services.PrintLoanCosts([]services.LoanCost{cost})
+}
+
+// compareOffers shows the cost of several loan offers side by side without creating any loan.
+func compareOffers() {
+	count := input.GetOfferCount(services.MinOffers, services.MaxOffers)
+
+	offers := make([]services.Offer, 0, count)
+	for i := range count {
+		offer := services.Offer{
+			Name:     input.GetOfferName(i + 1),
+			Amount:   input.GetInitialLoanAmount(),
+			Interest: input.GetInterestRate(),
+			Term:     input.GetTerm(),
+		}
+		if offer.Term == 0 {
+			offer.MonthlyPayment = input.GetMonthlyPaymentAmount()
+		}
+		offer.Fees = input.GetFees()
+		offers = append(offers, offer)
+	}
+
+	comparison, err := services.CompareOffers(offers)
+	if err != nil {
+		log.Error().Err(err).Msg("Error comparing offers")
+		return
+	}
+
+	services.PrintOfferComparison(comparison)
+}
+
+// changeLoanRate records a new interest rate of a variable rate loan and shows how it changes the payoff.
+func changeLoanRate(user *domain.User, srvc *services.UserService) {
+	var loans []domain.Loan
+	for _, loan := range user.Loans {
+		if loan.GetStatus().IsOpen() {
+			loans = append(loans, loan)
+		}
+	}
+
+	if len(loans) == 0 {
+		log.Warn().Msg("No open loans available to change their interest rate.")
+		return
+	}
+
+	loanID := input.GetLoanSelection(loans)
+
+	// If the user selects "exit", return to the main menu
+	if loanID == "" {
+		return
+	}
+
+	change := domain.RateChange{
+		EffectiveDate:  input.GetEffectiveDate(),
+		Rate:           input.GetInterestRate(),
+		MonthlyPayment: input.GetNewMonthlyPayment(),
+	}
+
+	impact, err := srvc.ChangeLoanRate(user.UserName, loanID, change)
+	if err != nil {
+		log.Error().Err(err).Msg("Error changing the interest rate")
 		return
 	}
-	log.Info().Msg("New loan created")
+
+	services.PrintRateChangeImpact(impact)
 }
 
 func modifyPaymentFromLoan(user *domain.User, srvc *services.UserService) {
 
 	// Select a loan to modify a payment
-	loanID := input.GetLoanSelection(user.Loans)
+	loanID := input.GetLoanSelection(user.CurrentLoans())
 
 	// If the user selects "exit", return to the main menu
 	if loanID == "" {
@@ -144,18 +554,80 @@ func modifyPaymentFromLoan(user *domain.User, srvc *services.UserService) {
 		return
 	}
 
-	paymentDate := input.GetPaymentSelection(selectedLoan.Payments)
+	// The disbursement and the charges of late installments are posted automatically, they cannot be modified
+	var payments []domain.Transaction
+	for _, entry := range selectedLoan.Ledger {
+		if !entry.IsAutomatic() {
+			payments = append(payments, entry)
+		}
+	}
+
+	paymentID := input.GetPaymentSelection(payments)
+
+	// If the user selects "exit", return to the main menu
+	if paymentID == "" {
+		return
+	}
+
 	newAmount := input.GetPaymentAmount()
 	newDesc := input.GetPaymentDescription()
+	newDate := input.GetNewPaymentDate()
 
-	err := srvc.ModifyPaymentFromLoan(user.UserName, loanID, paymentDate, newAmount, newDesc)
-	if err != nil {
+	err := srvc.ModifyPaymentFromLoan(user.UserName, loanID, paymentID, newAmount, newDesc, newDate)
+	switch {
+	case errors.Is(err, services.ErrOverpayment):
+		log.Warn().Err(err).Msg("The new amount pays more than the loan owes, the payment was not changed.")
+		return
+	case errors.Is(err, services.ErrLoanNotOpen):
+		log.Warn().Err(err).Msg("Reopen the loan before modifying its payments.")
+		return
+	case err != nil:
 		log.Error().Err(err).Msg("Error modifying payment")
 		return
 	}
 	log.Info().Msg("Payment modified")
 }
 
+// removePaymentFromLoan deletes a payment, fee, adjustment or refund entered by mistake.
+func removePaymentFromLoan(user *domain.User, srvc *services.UserService) {
+	loanID := input.GetLoanSelection(user.CurrentLoans())
+
+	// If the user selects "exit", return to the main menu
+	if loanID == "" {
+		return
+	}
+
+	selectedLoan := user.GetLoan(loanID)
+	if selectedLoan == nil {
+		log.Warn().Msg("Loan not found.")
+		return
+	}
+
+	// The disbursement and the charges of late installments are posted automatically, they cannot be removed
+	var payments []domain.Transaction
+	for _, entry := range selectedLoan.Ledger {
+		if !entry.IsAutomatic() {
+			payments = append(payments, entry)
+		}
+	}
+
+	paymentID := input.GetPaymentSelection(payments)
+	if paymentID == "" {
+		return
+	}
+
+	err := srvc.RemovePaymentFromLoan(user.UserName, loanID, paymentID)
+	switch {
+	case errors.Is(err, services.ErrLoanNotOpen):
+		log.Warn().Err(err).Msg("Reopen the loan before removing its payments.")
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Error removing payment")
+		return
+	}
+	log.Info().Msg("Payment removed")
+}
+
 func addPaymentToLoan(user *domain.User, srvc *services.UserService) {
 	if len(user.Loans) == 0 {
 		log.Warn().Msg("No loans available to add payments.")
@@ -163,7 +635,7 @@ func addPaymentToLoan(user *domain.User, srvc *services.UserService) {
 	}
 
 	// Select a loan to add a payment
-	loanID := input.GetLoanSelection(user.Loans)
+	loanID := input.GetLoanSelection(user.CurrentLoans())
 
 	// If the user selects "exit", return to the main menu
 	if loanID == "" {
@@ -172,19 +644,38 @@ func addPaymentToLoan(user *domain.User, srvc *services.UserService) {
 
 	amount := input.GetPaymentAmount()
 	description := input.GetPaymentDescription()
+	payment := domain.Transaction{Amount: amount, Description: description, DateTime: time.Now().Format(time.RFC3339)}
+
+	// Ask what to do with the part above the payoff amount, if any
+	mode := domain.OverpaymentReject
+	payoff, err := srvc.PayoffAmount(user.UserName, loanID, payment.DateTime)
+	if err == nil && amount > payoff+domain.PayoffTolerance {
+		if mode = input.GetOverpaymentChoice(payoff, amount-payoff); mode == "" {
+			log.Info().Msg("Payment cancelled")
+			return
+		}
+	}
 
-	err := srvc.AddPaymentToLoan(user.UserName, loanID, domain.Payment{Amount: amount, Description: description, DateTime: time.Now().Format(time.RFC3339)})
-	if err != nil {
+	added, err := srvc.AddPaymentToLoan(user.UserName, loanID, payment, mode)
+	switch {
+	case errors.Is(err, services.ErrLoanFullyPaid):
+		log.Warn().Msg("This loan is already fully paid.")
+		return
+	case errors.Is(err, services.ErrOverpayment):
+		log.Warn().Err(err).Msg("The payment is greater than what the loan owes, it was not added.")
+		return
+	case errors.Is(err, services.ErrLoanNotOpen):
+		log.Warn().Err(err).Msg("Reopen the loan before adding payments.")
+		return
+	case err != nil:
 		log.Error().Err(err).Msg("Error adding payment")
 		return
 	}
 
-	log.Info().Float64("amount", amount).Msg("Payment added")
-}
-
-// Generate a unique LoanID based on existing loans
-func generateUniqueLoanID(user domain.User) string {
-	return strconv.Itoa(len(user.Loans) + 1) // Simple increment based on the number of existing loans
+	log.Info().Stringer("amount", added.Amount).Msg("Payment added")
+	if credit := user.GetLoan(loanID).Credit(); credit > 0 {
+		log.Info().Stringer("credit", credit).Msg("Loan paid off, the excess is recorded as a credit to refund")
+	}
 }
 
 func viewPaymentHistory(user *domain.User) {
@@ -225,3 +716,39 @@ func viewPaymentHistory(user *domain.User) {
 		}
 	}
 }
+
+func viewAmortizationSchedule(user *domain.User) {
+	if len(user.Loans) == 0 {
+		log.Warn().Msg("No loans available to view the amortization schedule.")
+		return
+	}
+
+	for {
+		log.Info().Msg("Select a loan to view its amortization schedule:")
+		loanID := input.GetLoanSelection(user.Loans)
+
+		// If the user selects "exit", return to the main menu
+		if loanID == "" {
+			return
+		}
+
+		selectedLoan := user.GetLoan(loanID)
+		if selectedLoan == nil {
+			log.Warn().Msg("Loan not found.")
+			return
+		}
+
+		services.PrintAmortizationSchedule(*selectedLoan)
+
+		// Ask the user if they want to go back to the main menu or exit
+		log.Info().Msg("Press 'Enter' to go back to the main menu or type 'exit' to exit:")
+		inputStr := input.GetUserInput()
+
+		if inputStr == "exit" {
+			log.Info().Msg("Exiting the program.")
+			return
+		} else {
+			input.ClearScreen()
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/adapters/rest"
+	"github.com/zapisanchez/loanMgr/internal/config"
+	"github.com/zapisanchez/loanMgr/internal/core/services"
+
+	"github.com/rs/zerolog/log"
+)
+
+// shutdownTimeout is how long the server waits for the requests in progress when stopping.
+const shutdownTimeout = 10 * time.Second
+
+// serveCommand runs the HTTP API until the process is interrupted.
+func serveCommand(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	repo, err := newRepo(cfg)
+	if err != nil {
+		return fmt.Errorf("error initializing repository: %w", err)
+	}
+	defer closeRepo(repo)
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           rest.NewServer(services.NewUserService(repo)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	fmt.Printf("Listening on http://%s\n", *addr)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("error running server: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Every change is saved by the request that makes it, so only the requests in progress need to finish
+	log.Info().Msg("Shutting down the server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error shutting down server: %w", err)
+	}
+	return nil
+}
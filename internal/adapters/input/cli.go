@@ -3,7 +3,11 @@ package input
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/zapisanchez/loanMgr/internal/core/domain"
 
@@ -40,11 +44,9 @@ func GetLoanName() string {
 }
 
 // GetPaymentAmount prompts the user for a payment amount.
-func GetPaymentAmount() float64 {
-	var amount float64
+func GetPaymentAmount() domain.Money {
 	fmt.Println("Enter the payment amount:")
-	fmt.Scanln(&amount)
-	return amount
+	return readAmount()
 }
 
 // GetPaymentDescription prompts the user for a payment description.
@@ -56,27 +58,344 @@ func GetPaymentDescription() string {
 }
 
 // GetInitialLoanAmount prompts the user for the initial loan amount.
-func GetInitialLoanAmount() float64 {
-	var amount float64
+func GetInitialLoanAmount() domain.Money {
 	fmt.Println("Enter the initial loan amount:")
-	fmt.Scanln(&amount)
-	return amount
+	return readAmount()
 }
 
 // GetMonthlyPaymentAmount prompts the user for the monthly payment amount.
-func GetMonthlyPaymentAmount() float64 {
-	var amount float64
+func GetMonthlyPaymentAmount() domain.Money {
 	fmt.Println("Enter the monthly payment amount:")
-	fmt.Scanln(&amount)
-	return amount
+	return readAmount()
 }
 
-// GetInitialLoanAmount prompts the user for the initial loan amount.
+// GetInterestRate prompts the user for the annual interest rate, asking again until the input is a valid rate.
 func GetInterestRate() float64 {
-	var rate float64
 	fmt.Println("Enter the interest rate:")
-	fmt.Scanln(&rate)
-	return rate
+	return readRate()
+}
+
+// GetPenaltyRate prompts the user for the annual rate charged on the unpaid part of a late installment.
+func GetPenaltyRate() float64 {
+	fmt.Println("Enter the annual penalty interest rate charged on the late amount (0 for none):")
+	return readRate()
+}
+
+// GetLateFee prompts the user for the fixed fee charged for each late installment. An empty line is zero.
+func GetLateFee() domain.Money {
+	fmt.Println("Enter the late fee charged for each late installment (0 or empty for none):")
+	return readAmountOrZero()
+}
+
+// GetGraceDays prompts the user for the days after the due date before an installment is late.
+// An empty line is zero.
+func GetGraceDays() int {
+	fmt.Println("Enter the days after the due date before an installment is late (empty for 0):")
+	for {
+		value, err := readLine()
+		if err != nil || value == "" {
+			return 0
+		}
+
+		days, err := strconv.Atoi(value)
+		if err == nil && days >= 0 {
+			return days
+		}
+		fmt.Printf("%q is not a valid number of days, try again:\n", value)
+	}
+}
+
+// GetNewPaymentDate prompts the user for the corrected date of a payment. Returns an empty date to keep the current one.
+func GetNewPaymentDate() string {
+	fmt.Println("Enter the new date of the payment (YYYY-MM-DD, empty to keep it):")
+	for {
+		value, err := readLine()
+		if err != nil || value == "" {
+			return ""
+		}
+
+		date, err := domain.ParsePaymentDate(value)
+		if err == nil {
+			return date
+		}
+		fmt.Printf("%q is not a valid date, use the YYYY-MM-DD format:\n", value)
+	}
+}
+
+// readRate reads an annual rate in percent, asking again until the input is a valid rate.
+func readRate() float64 {
+	for {
+		value, err := readLine()
+		if err != nil {
+			return 0 // Nothing more to read, the loan validation reports the missing rate
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		switch {
+		case err != nil || math.IsNaN(rate) || math.IsInf(rate, 0):
+			fmt.Printf("%q is not a valid interest rate, enter a number like 3.5:\n", value)
+		case rate < 0:
+			fmt.Println("The interest rate cannot be negative, try again:")
+		default:
+			return rate
+		}
+	}
+}
+
+// GetEffectiveDate prompts the user for the date a change applies from, asking again until it is
+// a valid date. An empty line is today.
+func GetEffectiveDate() string {
+	fmt.Println("Enter the date the change applies from (YYYY-MM-DD, empty for today):")
+	for {
+		value, err := readLine()
+		if err != nil {
+			value = ""
+		}
+
+		date, err := domain.ParsePaymentDate(value)
+		if err == nil {
+			return date
+		}
+		fmt.Printf("%q is not a valid date, use the YYYY-MM-DD format:\n", value)
+	}
+}
+
+// GetNewMonthlyPayment prompts the user for a new monthly payment. Returns zero to keep the current one.
+func GetNewMonthlyPayment() domain.Money {
+	fmt.Println("Enter the new monthly payment (0 or empty to keep the current one):")
+	return readAmountOrZero()
+}
+
+// GetOfferCount prompts the user for how many offers to compare, asking again until it is between min and max.
+func GetOfferCount(min, max int) int {
+	fmt.Printf("How many offers do you want to compare (%d to %d)?\n", min, max)
+	for {
+		value, err := readLine()
+		if err != nil {
+			return 0
+		}
+
+		count, err := strconv.Atoi(value)
+		if err == nil && count >= min && count <= max {
+			return count
+		}
+		fmt.Printf("Enter a number from %d to %d:\n", min, max)
+	}
+}
+
+// GetOfferName prompts the user for the name of an offer, the bank for example. An empty name is "Offer N".
+func GetOfferName(number int) string {
+	fmt.Printf("Enter the name of offer %d:\n", number)
+	name, err := readLine()
+	if err != nil || name == "" {
+		return fmt.Sprintf("Offer %d", number)
+	}
+	return name
+}
+
+// GetTerm prompts the user for the term of a loan in months. Returns zero when the line is empty.
+func GetTerm() int {
+	fmt.Println("Enter the term in months, or leave it empty to enter the monthly payment:")
+	return readMonths()
+}
+
+// GetStartDate prompts the user for the date a loan was granted. Returns the default date when the
+// line is empty.
+func GetStartDate(defaultDate time.Time) string {
+	fmt.Printf("Enter the date the loan was granted (YYYY-MM-DD, empty for %s):\n", defaultDate.Format(time.DateOnly))
+	for {
+		value, err := readLine()
+		if err != nil || value == "" {
+			return defaultDate.Format(time.RFC3339)
+		}
+
+		date, err := domain.ParsePaymentDate(value)
+		if err == nil {
+			return date
+		}
+		fmt.Printf("%q is not a valid date, use the YYYY-MM-DD format:\n", value)
+	}
+}
+
+// GetDueDay prompts the user for the day of the month the installments of a loan are due, asking
+// again until it is between 1 and 31. Returns the default day when the line is empty.
+func GetDueDay(defaultDay int) int {
+	fmt.Printf("Enter the day of the month the installments are due (1 to 31, empty for %d):\n", defaultDay)
+	for {
+		value, err := readLine()
+		if err != nil || value == "" {
+			return defaultDay
+		}
+
+		day, err := strconv.Atoi(value)
+		if err == nil && day >= 1 && day <= 31 {
+			return day
+		}
+		fmt.Println("Enter a day from 1 to 31:")
+	}
+}
+
+// GetLoanTerm prompts the user for the number of monthly installments of a loan. Returns zero when
+// the line is empty, for loans that run until paid off.
+func GetLoanTerm() int {
+	fmt.Println("Enter the term of the loan in months (empty if it runs until paid off):")
+	return readMonths()
+}
+
+// readMonths reads a positive number of months, asking again until the input is valid. An empty
+// line is zero.
+func readMonths() int {
+	for {
+		value, err := readLine()
+		if err != nil || value == "" {
+			return 0
+		}
+
+		months, err := strconv.Atoi(value)
+		if err == nil && months > 0 {
+			return months
+		}
+		fmt.Printf("%q is not a valid number of months, try again:\n", value)
+	}
+}
+
+// GetFees prompts the user for the fees of a loan until an empty name is entered.
+func GetFees() []domain.Fee {
+	var fees []domain.Fee
+	for {
+		fmt.Println("Enter the name of a fee (opening fee, insurance...) or leave it empty to finish:")
+		name, err := readLine()
+		if err != nil || name == "" {
+			return fees
+		}
+
+		fmt.Println("Enter the fee amount:")
+		amount := readAmount()
+		if amount == 0 {
+			return fees
+		}
+
+		fmt.Println("Is it charged with every monthly installment? y/n")
+		recurring, _ := readLine()
+
+		fees = append(fees, domain.Fee{Name: name, Amount: amount, Recurring: strings.EqualFold(recurring, "y")})
+	}
+}
+
+// GetExtraMonthlyPayment prompts the user for an amount to pay every month on top of the monthly payment.
+func GetExtraMonthlyPayment() domain.Money {
+	fmt.Println("Enter the extra amount to pay every month (0 for none):")
+	return readAmountOrZero()
+}
+
+// GetLumpSums prompts the user for one-off extra payments until an empty date is entered.
+func GetLumpSums() []domain.Prepayment {
+	var lumpSums []domain.Prepayment
+	for {
+		fmt.Println("Enter the date of a lump sum (YYYY-MM-DD) or leave it empty to finish:")
+		value, err := readLine()
+		if err != nil || value == "" {
+			return lumpSums
+		}
+
+		date, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+		if err != nil {
+			fmt.Printf("%q is not a valid date, use the YYYY-MM-DD format.\n", value)
+			continue
+		}
+
+		fmt.Println("Enter the lump sum amount:")
+		amount := readAmount()
+		if amount == 0 {
+			return lumpSums
+		}
+		lumpSums = append(lumpSums, domain.Prepayment{Date: date, Amount: amount})
+	}
+}
+
+// GetMonthlyBudget prompts the user for the total amount to pay every month across all loans.
+func GetMonthlyBudget() domain.Money {
+	fmt.Println("Enter the total amount you can pay every month across all loans:")
+	return readAmount()
+}
+
+// GetLoanOrder prompts the user for the loan IDs in the order to pay them off. Returns nil when the line is empty.
+func GetLoanOrder() []string {
+	fmt.Println("Enter the LoanIDs in the order you want to pay them off, separated by commas, or leave it empty to skip:")
+	value, err := readLine()
+	if err != nil || value == "" {
+		return nil
+	}
+
+	var order []string
+	for _, loanID := range strings.Split(value, ",") {
+		if loanID = strings.TrimSpace(loanID); loanID != "" {
+			order = append(order, loanID)
+		}
+	}
+	return order
+}
+
+// readAmount reads a positive amount of money from the standard input, asking again
+// until the input is valid.
+func readAmount() domain.Money {
+	for {
+		amount, ok := readMoney()
+		switch {
+		case !ok:
+			return 0 // Nothing more to read, the validation reports the missing amount
+		case amount <= 0:
+			fmt.Println("The amount must be greater than zero, try again:")
+		default:
+			return amount
+		}
+	}
+}
+
+// readAmountOrZero reads an amount of money that may be zero, an empty line means zero.
+func readAmountOrZero() domain.Money {
+	for {
+		amount, ok := readMoney()
+		switch {
+		case !ok:
+			return 0
+		case amount < 0:
+			fmt.Println("The amount cannot be negative, try again:")
+		default:
+			return amount
+		}
+	}
+}
+
+// readMoney reads an amount of money, asking again until the input is a number.
+// It returns false when there is nothing more to read. An empty line is zero.
+func readMoney() (domain.Money, bool) {
+	for {
+		value, err := readLine()
+		if err != nil {
+			return 0, false
+		}
+		if value == "" {
+			return 0, true
+		}
+
+		amount, err := domain.ParseMoney(value)
+		if err != nil {
+			fmt.Printf("%q is not a valid amount, enter a number like 1234.56:\n", value)
+			continue
+		}
+		return amount, true
+	}
+}
+
+// readLine reads a line from the standard input without the surrounding spaces.
+func readLine() (string, error) {
+	reader := bufio.NewReader(os.Stdin)
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
 }
 
 // GetUserChoice prompts the user for their choice from the menu.
@@ -114,12 +433,12 @@ func GetLoanSelection(loans []domain.Loan) string {
 	}
 }
 
-// GetPaymentSelection prompts the user to select a payment by index. Return datetime of the selected payment.
-func GetPaymentSelection(payments []domain.Payment) string {
+// GetPaymentSelection prompts the user to select a payment by index. Return the ID of the selected payment.
+func GetPaymentSelection(payments []domain.Transaction) string {
 	for {
 		fmt.Println("Payment History:")
 		for i, payment := range payments {
-			fmt.Printf("%d. Date: %s, Description: %s, Amount: %.2f\n", i+1, payment.DateTime, payment.Description, payment.Amount)
+			fmt.Printf("%d. Date: %s, Type: %s, Description: %s, Amount: %s\n", i+1, payment.DateTime, payment.Type, payment.Description, payment.Amount)
 		}
 
 		fmt.Println("Enter the number of the payment to select it or type 'exit' to return to the main menu:")
@@ -135,6 +454,51 @@ func GetPaymentSelection(payments []domain.Payment) string {
 			continue
 		}
 
-		return payments[selection-1].DateTime
+		return payments[selection-1].ID
+	}
+}
+
+// GetStatusSelection prompts the user to select one of the given loan statuses. Returns an empty status to go back.
+func GetStatusSelection(statuses []domain.LoanStatus) domain.LoanStatus {
+	for {
+		fmt.Println("Available statuses:")
+		for i, status := range statuses {
+			fmt.Printf("%d. %s\n", i+1, status)
+		}
+
+		fmt.Println("Enter the number of the new status or type 'exit' to return to the main menu:")
+		var selection int
+		fmt.Scanf("%d", &selection)
+
+		if selection == 0 {
+			return "" // Return to the main menu
+		}
+
+		if selection < 1 || selection > len(statuses) {
+			fmt.Println("Invalid selection. Please try again.")
+			continue
+		}
+
+		return statuses[selection-1]
+	}
+}
+
+// GetOverpaymentChoice asks what to do with a payment greater than the payoff amount. Returns an empty mode to cancel the payment.
+func GetOverpaymentChoice(payoff, excess domain.Money) domain.OverpaymentMode {
+	for {
+		fmt.Printf("The payment is %s more than the %s left to pay off the loan.\n", excess, payoff)
+		fmt.Printf("1) Pay only %s\n", payoff)
+		fmt.Printf("2) Pay it all and record %s as a credit to refund\n", excess)
+		fmt.Println("3) Cancel the payment")
+
+		switch GetUserChoice() {
+		case "1":
+			return domain.OverpaymentCap
+		case "2":
+			return domain.OverpaymentCredit
+		case "3":
+			return ""
+		}
+		fmt.Println("Invalid choice. Please try again.")
 	}
 }
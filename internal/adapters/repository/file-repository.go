@@ -2,44 +2,84 @@ package repository
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/zapisanchez/loanMgr/internal/config"
 	"github.com/zapisanchez/loanMgr/internal/core/domain"
 
 	"github.com/rs/zerolog/log"
 )
 
-const dataDir = "loan_data/"
-const deletedDir = "loan_data/deleted/"
+// backupSuffix is appended to a user file to name the copy of its previous version.
+const backupSuffix = ".bak"
+
+// lockFileName is the lock file created in the data directory while loanMgr is running.
+const lockFileName = ".lock"
+
+// deletedDirName is the subdirectory of the data directory holding the deleted users.
+const deletedDirName = "deleted"
 
 type FileRepo struct {
-	users   map[string]*domain.User
-	deleted map[string]*domain.User
+	dataDir    string
+	deletedDir string
+	users      map[string]*domain.User
+	deleted    map[string]*domain.User
+	lock       *dataLock
 }
 
-func NewFileRepo() (*FileRepo, error) {
+func NewFileRepo(cfg config.Config) (*FileRepo, error) {
+	dataDir := cfg.DataDir
+	deletedDir := filepath.Join(dataDir, deletedDirName)
+
+	// Create the data directories on the first run
+	if err := os.MkdirAll(deletedDir, os.ModePerm); err != nil {
+		log.Error().Err(err).Msg("Error creating data directory")
+		return nil, fmt.Errorf("error creating data directory: %w", err)
+	}
+
+	// Lock the data directory until Close, so no other process saves over our changes
+	lock, err := acquireLock(filepath.Join(dataDir, lockFileName))
+	if err != nil {
+		log.Error().Err(err).Str("dir", dataDir).Msg("Error locking data directory")
+		return nil, err
+	}
+
 	// Load all users' data from files
-	users, err := loadUsers()
+	users, err := loadUsers(dataDir)
 	if err != nil {
+		lock.release()
 		log.Error().Err(err).Msg("Error loading users")
 		return nil, err
 	}
 
 	// Load all deleted users' data from files
-	deleted, err := loadDeletedUsers()
+	deleted, err := loadDeletedUsers(deletedDir)
 	if err != nil {
+		lock.release()
 		log.Error().Err(err).Msg("Error loading deleted users")
 		return nil, err
 	}
 
 	return &FileRepo{
-		users:   users,
-		deleted: deleted,
+		dataDir:    dataDir,
+		deletedDir: deletedDir,
+		users:      users,
+		deleted:    deleted,
+		lock:       lock,
 	}, nil
 }
 
+// Close releases the lock on the data directory.
+func (r *FileRepo) Close() error {
+	return r.lock.release()
+}
+
 // GetUser gets an user's data from the map.
 func (r *FileRepo) GetUser(userName string) *domain.User {
 	return r.users[userName]
@@ -66,26 +106,25 @@ func (r *FileRepo) MoveUserToDeleted(userID string) error {
 func (r *FileRepo) PersistUserData() error {
 	// Save all users to files
 	for _, user := range r.users {
-		err := saveUser(*user)
+		err := saveUser(*user, r.dataDir)
 		if err != nil {
 			return err
 		}
 	}
-	r.users = nil
 
+	// The maps are kept, so the repository can be used again after saving
 	for _, deleted := range r.deleted {
-		err := moveUserToDeleted(deleted.UserName)
+		err := moveUserToDeleted(deleted.UserName, r.dataDir, r.deletedDir)
 		if err != nil {
 			return err
 		}
 	}
 
-	r.deleted = nil
 	return nil
 }
 
 // loadUsers loads all users' data from files.
-func loadUsers() (map[string]*domain.User, error) {
+func loadUsers(dataDir string) (map[string]*domain.User, error) {
 	users := make(map[string]*domain.User)
 
 	// Read all files in the data directory
@@ -97,16 +136,24 @@ func loadUsers() (map[string]*domain.User, error) {
 
 	// Load user data from each file
 	for _, file := range files {
-		if file.IsDir() {
+		// Skip anything that is not a user file, like the SQLite database
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
 			continue
 		}
 
-		userName := file.Name()[:len(file.Name())-5]
+		userName := strings.TrimSuffix(file.Name(), ".json")
 		user, err := loadUser(userName, dataDir)
 		if err != nil {
 			return users, err
 		}
 
+		// Data repaired on load is saved right away so the new IDs stay stable
+		if repairUser(&user) {
+			if err := saveUser(user, dataDir); err != nil {
+				return users, err
+			}
+		}
+
 		users[user.UserName] = &user
 	}
 
@@ -115,7 +162,7 @@ func loadUsers() (map[string]*domain.User, error) {
 }
 
 // loadDeletedUsers loads all deleted users' data from files.
-func loadDeletedUsers() (map[string]*domain.User, error) {
+func loadDeletedUsers(deletedDir string) (map[string]*domain.User, error) {
 	users := make(map[string]*domain.User)
 
 	// Read all files in the deleted directory
@@ -127,16 +174,23 @@ func loadDeletedUsers() (map[string]*domain.User, error) {
 
 	// Load user data from each file
 	for _, file := range files {
-		if file.IsDir() {
+		// Skip anything that is not a user file, like the SQLite database
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
 			continue
 		}
 
-		userName := file.Name()[:len(file.Name())-5]
+		userName := strings.TrimSuffix(file.Name(), ".json")
 		user, err := loadUser(userName, deletedDir)
 		if err != nil {
 			return users, err
 		}
 
+		if repairUser(&user) {
+			if err := saveUser(user, deletedDir); err != nil {
+				return users, err
+			}
+		}
+
 		users[user.UserName] = &user
 	}
 
@@ -144,11 +198,47 @@ func loadDeletedUsers() (map[string]*domain.User, error) {
 	return users, nil
 }
 
-// loadUser loads a user's data from a file.
+// loadUser loads a user's data from a file. When the file cannot be read or parsed, the
+// backup written by the previous save is used and the corrupted file is set aside.
 func loadUser(userName, dataPath string) (domain.User, error) {
+	filePath := filepath.Join(dataPath, userName+".json")
+	user, err := readUserFile(filePath)
+	if err != nil {
+		backupPath := filePath + backupSuffix
+		backup, backupErr := readUserFile(backupPath)
+		if backupErr != nil {
+			return user, err
+		}
+
+		// Keep the corrupted file for inspection, and so the next save does not replace the good backup with it
+		corruptPath := fmt.Sprintf("%s.corrupt-%s", filePath, time.Now().Format("20060102150405"))
+		if renameErr := os.Rename(filePath, corruptPath); renameErr != nil && !errors.Is(renameErr, fs.ErrNotExist) {
+			log.Error().Err(renameErr).Str("file", filePath).Msg("Error setting aside corrupted user file")
+		}
+
+		// Put the backup back in place so the user is still found if nothing is saved
+		if restoreErr := restoreBackup(backupPath, filePath); restoreErr != nil {
+			log.Error().Err(restoreErr).Str("file", filePath).Msg("Error restoring user file from backup")
+		}
+
+		log.Error().Err(err).Str("file", filePath).Str("backup", backupPath).Str("corrupted", corruptPath).
+			Msg("User file is corrupted, the backup was loaded instead")
+		user = backup
+	}
+
+	// Older files store money as floats, they are upgraded the next time they are saved
+	if user.Version < domain.DataVersion {
+		log.Info().Str("user", userName).Int("version", user.Version).Msg("User data uses an older format, it will be upgraded on save")
+	}
+
+	log.Info().Str("user", userName).Msg("User data loaded successfully")
+	return user, nil
+}
+
+// readUserFile reads and parses a user file.
+func readUserFile(filePath string) (domain.User, error) {
 	var user domain.User
 
-	filePath := fmt.Sprintf("%s%s.json", dataPath, userName)
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		log.Error().Err(err).Str("file", filePath).Msg("Error reading user file")
@@ -158,16 +248,15 @@ func loadUser(userName, dataPath string) (domain.User, error) {
 	// Parse JSON data
 	err = json.Unmarshal(data, &user)
 	if err != nil {
-		log.Error().Err(err).Msg("Error unmarshalling user data")
+		log.Error().Err(err).Str("file", filePath).Msg("Error unmarshalling user data")
 		return user, fmt.Errorf("error unmarshalling user data: %w", err)
 	}
 
-	log.Info().Str("user", userName).Msg("User data loaded successfully")
 	return user, nil
 }
 
 // saveUser saves a user's data to a file.
-func saveUser(user domain.User) error {
+func saveUser(user domain.User, dataDir string) error {
 	// Create directory if not exists
 	err := os.MkdirAll(dataDir, os.ModePerm)
 	if err != nil {
@@ -175,6 +264,9 @@ func saveUser(user domain.User) error {
 		return fmt.Errorf("error creating data directory: %w", err)
 	}
 
+	// Always store the data in the current format
+	user.Version = domain.DataVersion
+
 	// Convert user data to JSON
 	userData, err := json.MarshalIndent(user, "", "  ")
 	if err != nil {
@@ -183,8 +275,8 @@ func saveUser(user domain.User) error {
 	}
 
 	// Save JSON data to file
-	filePath := fmt.Sprintf("%s%s.json", dataDir, user.UserName)
-	if err := os.WriteFile(filePath, userData, 0644); err != nil {
+	filePath := filepath.Join(dataDir, user.UserName+".json")
+	if err := writeFileAtomic(filePath, userData); err != nil {
 		log.Error().Err(err).Str("file", filePath).Msg("Error saving user data to file")
 		return err
 	}
@@ -193,8 +285,84 @@ func saveUser(user domain.User) error {
 	return nil
 }
 
+// writeFileAtomic replaces a file without ever leaving it half written: the data goes
+// to a temporary file in the same directory, is flushed to disk and then renamed over
+// the original. The previous version is kept with the backup suffix.
+func writeFileAtomic(filePath string, data []byte) error {
+	dir := filepath.Dir(filePath)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temporary file if anything goes wrong before the rename
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing temporary file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return fmt.Errorf("error setting file permissions: %w", err)
+	}
+
+	// Keep the current version as backup. A hard link leaves the original in place,
+	// so there is no moment where the file is missing.
+	if _, err := os.Stat(filePath); err == nil {
+		backupPath := filePath + backupSuffix
+		if err := os.Remove(backupPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error removing old backup: %w", err)
+		}
+		if err := os.Link(filePath, backupPath); err != nil {
+			// Some file systems do not support hard links
+			if err := copyFile(filePath, backupPath); err != nil {
+				return fmt.Errorf("error creating backup: %w", err)
+			}
+		}
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("error replacing file: %w", err)
+	}
+
+	// Persist the rename itself. Not every platform can sync a directory, so errors are ignored.
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// restoreBackup writes the content of the backup to the user file.
+func restoreBackup(backupPath, filePath string) error {
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filePath, data)
+}
+
+// copyFile copies the content of a file to a new file.
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
 // moveUserToDeleted moves the user's JSON file to the deleted directory.
-func moveUserToDeleted(userID string) error {
+func moveUserToDeleted(userID, dataDir, deletedDir string) error {
 	// Define the paths
 	userFilePath := filepath.Join(dataDir, userID+".json")
 
@@ -203,6 +371,11 @@ func moveUserToDeleted(userID string) error {
 		return err
 	}
 
+	// Users loaded from the deleted directory were already moved
+	if _, err := os.Stat(userFilePath); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
 	// Move the file to the deleted directory
 	newFilePath := filepath.Join(deletedDir, userID+".json")
 	err := os.Rename(userFilePath, newFilePath)
@@ -210,5 +383,13 @@ func moveUserToDeleted(userID string) error {
 		return err
 	}
 
+	// The backup goes with the user, so a new user with the same name never recovers it
+	backupPath := userFilePath + backupSuffix
+	if _, err := os.Stat(backupPath); err == nil {
+		if err := os.Rename(backupPath, newFilePath+backupSuffix); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
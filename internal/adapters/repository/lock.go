@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrDataInUse is returned when another loanMgr process is using the same data.
+var ErrDataInUse = errors.New("the loan data is in use by another loanMgr process")
+
+// dataLock is an advisory lock held while the data is loaded in memory, so two
+// processes cannot load the same data and overwrite each other's changes when saving.
+type dataLock struct {
+	file *os.File
+}
+
+// acquireLock locks the given lock file, creating it if needed. It fails with
+// ErrDataInUse instead of waiting when another process holds the lock.
+func acquireLock(path string) (*dataLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %w", err)
+	}
+
+	if err := lockFile(file); err != nil {
+		defer file.Close()
+		if errors.Is(err, ErrDataInUse) {
+			// The holder writes its PID in the lock file
+			owner, _ := io.ReadAll(file)
+			if pid := strings.TrimSpace(string(owner)); pid != "" {
+				return nil, fmt.Errorf("%w (pid %s, lock file %s)", ErrDataInUse, pid, path)
+			}
+			return nil, fmt.Errorf("%w (lock file %s)", ErrDataInUse, path)
+		}
+		return nil, fmt.Errorf("error locking %s: %w", path, err)
+	}
+
+	// Record who holds the lock to help the other process report it
+	if err := file.Truncate(0); err == nil {
+		fmt.Fprintf(file, "%d\n", os.Getpid())
+	}
+
+	return &dataLock{file: file}, nil
+}
+
+// release unlocks and closes the lock file.
+func (l *dataLock) release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	_ = l.file.Truncate(0)
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
@@ -0,0 +1,20 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package repository
+
+import (
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// lockFile does nothing on platforms without flock, loanMgr must not be run twice at the same time there.
+func lockFile(file *os.File) error {
+	log.Warn().Str("file", file.Name()).Msg("File locking is not supported on this platform")
+	return nil
+}
+
+// unlockFile does nothing on platforms without flock.
+func unlockFile(file *os.File) error {
+	return nil
+}
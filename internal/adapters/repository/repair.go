@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+
+	"github.com/rs/zerolog/log"
+)
+
+// repairUser fixes the data written by older versions of loanMgr: loans sharing the same
+// ID, loan histories stored before the ledger, payments without an ID, loans without a
+// status and wrong payoff times. It returns true when the user changed and must be saved.
+func repairUser(user *domain.User) bool {
+	renumbered := user.RepairLoanIDs()
+	for newID, oldID := range renumbered {
+		log.Warn().Str("user", user.UserName).Str("old_loan_id", oldID).Str("new_loan_id", newID).Msg("Duplicated loan ID renumbered")
+	}
+
+	migrated := user.MigrateLedgers()
+	if migrated > 0 {
+		log.Info().Str("user", user.UserName).Int("loans", migrated).Msg("Moved the payment history into the ledger")
+	}
+
+	assigned := user.AssignPaymentIDs()
+	if assigned > 0 {
+		log.Info().Str("user", user.UserName).Int("payments", assigned).Msg("Assigned IDs to payments")
+	}
+
+	// Loans stored before the status existed get one from their balance
+	updated := user.UpdateLoanStatuses()
+	if updated > 0 {
+		log.Info().Str("user", user.UserName).Int("loans", updated).Msg("Updated loan statuses")
+	}
+
+	recalculated := user.RecalculatePayoffTimes()
+	if recalculated > 0 {
+		log.Info().Str("user", user.UserName).Int("loans", recalculated).Msg("Recalculated payoff times")
+	}
+
+	return len(renumbered) > 0 || migrated > 0 || assigned > 0 || updated > 0 || recalculated > 0
+}
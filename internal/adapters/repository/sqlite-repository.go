@@ -0,0 +1,546 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zapisanchez/loanMgr/internal/config"
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+
+	"github.com/rs/zerolog/log"
+	_ "modernc.org/sqlite" // Pure Go SQLite driver, no cgo required
+)
+
+// migrations holds the database schema changes. The index of each entry plus one is
+// the schema version stored in PRAGMA user_version, so new changes are only appended.
+var migrations = []string{
+	`CREATE TABLE users (
+		user_name TEXT PRIMARY KEY,
+		deleted   INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE loans (
+		id               INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_name        TEXT NOT NULL REFERENCES users(user_name) ON DELETE CASCADE,
+		position         INTEGER NOT NULL,
+		loan_id          TEXT NOT NULL,
+		loan_name        TEXT NOT NULL,
+		amount           INTEGER NOT NULL,
+		remaining_amount INTEGER NOT NULL,
+		total_paid       INTEGER NOT NULL,
+		interest_paid    INTEGER NOT NULL,
+		interest         REAL NOT NULL,
+		start_date       TEXT NOT NULL,
+		monthly_payment  INTEGER NOT NULL,
+		time_paid_off    REAL NOT NULL
+	);
+	CREATE INDEX loans_user_name ON loans(user_name, loan_id);
+
+	CREATE TABLE payments (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		loan        INTEGER NOT NULL REFERENCES loans(id) ON DELETE CASCADE,
+		position    INTEGER NOT NULL,
+		date_time   TEXT NOT NULL,
+		description TEXT NOT NULL,
+		amount      INTEGER NOT NULL,
+		interest    INTEGER NOT NULL,
+		principal   INTEGER NOT NULL
+	);
+	CREATE INDEX payments_loan ON payments(loan);`,
+
+	`ALTER TABLE payments ADD COLUMN payment_id TEXT NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE users ADD COLUMN last_loan_id INTEGER NOT NULL DEFAULT 0;`,
+
+	`ALTER TABLE loans ADD COLUMN status TEXT NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE loans ADD COLUMN credit INTEGER NOT NULL DEFAULT 0;`,
+
+	`CREATE TABLE rate_changes (
+		id              INTEGER PRIMARY KEY AUTOINCREMENT,
+		loan            INTEGER NOT NULL REFERENCES loans(id) ON DELETE CASCADE,
+		position        INTEGER NOT NULL,
+		effective_date  TEXT NOT NULL,
+		rate            REAL NOT NULL,
+		monthly_payment INTEGER NOT NULL
+	);
+	CREATE INDEX rate_changes_loan ON rate_changes(loan);`,
+
+	`CREATE TABLE fees (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		loan      INTEGER NOT NULL REFERENCES loans(id) ON DELETE CASCADE,
+		position  INTEGER NOT NULL,
+		name      TEXT NOT NULL,
+		amount    INTEGER NOT NULL,
+		recurring INTEGER NOT NULL
+	);
+	CREATE INDEX fees_loan ON fees(loan);`,
+
+	`ALTER TABLE loans ADD COLUMN due_day INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE loans ADD COLUMN term INTEGER NOT NULL DEFAULT 0;`,
+
+	`ALTER TABLE loans ADD COLUMN penalty_grace_days INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE loans ADD COLUMN penalty_late_fee INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE loans ADD COLUMN penalty_rate REAL NOT NULL DEFAULT 0;
+	ALTER TABLE payments ADD COLUMN type TEXT NOT NULL DEFAULT '';
+	ALTER TABLE payments ADD COLUMN installment INTEGER NOT NULL DEFAULT 0;`,
+
+	// The balances are derived from the ledger, which the loans open with their disbursement when loaded.
+	// The stored balance is kept until then, so the migrated ledger can be adjusted to it.
+	`ALTER TABLE loans ADD COLUMN legacy_balance INTEGER;
+	UPDATE loans SET legacy_balance = remaining_amount - credit;
+	ALTER TABLE payments RENAME TO ledger;
+	ALTER TABLE ledger RENAME COLUMN payment_id TO transaction_id;
+	ALTER TABLE ledger DROP COLUMN interest;
+	ALTER TABLE ledger DROP COLUMN principal;
+	DROP INDEX payments_loan;
+	CREATE INDEX ledger_loan ON ledger(loan);
+	ALTER TABLE loans DROP COLUMN remaining_amount;
+	ALTER TABLE loans DROP COLUMN total_paid;
+	ALTER TABLE loans DROP COLUMN interest_paid;
+	ALTER TABLE loans DROP COLUMN credit;`,
+}
+
+// SQLiteRepo stores users, loans and payments in an SQLite database. Like FileRepo it
+// keeps the data in memory and writes it back on PersistUserData.
+type SQLiteRepo struct {
+	db      *sql.DB
+	users   map[string]*domain.User
+	deleted map[string]*domain.User
+	lock    *dataLock
+}
+
+func NewSQLiteRepo(cfg config.Config) (*SQLiteRepo, error) {
+	dbPath := cfg.DBPath
+	if err := os.MkdirAll(filepath.Dir(dbPath), os.ModePerm); err != nil {
+		log.Error().Err(err).Msg("Error creating database directory")
+		return nil, fmt.Errorf("error creating database directory: %w", err)
+	}
+
+	// SQLite protects each write, but the data is kept in memory until PersistUserData,
+	// so the database is locked for the whole session like the data directory.
+	lock, err := acquireLock(dbPath + ".lock")
+	if err != nil {
+		log.Error().Err(err).Str("file", dbPath).Msg("Error locking database")
+		return nil, err
+	}
+
+	db, err := openDatabase(dbPath)
+	if err != nil {
+		lock.release()
+		return nil, err
+	}
+
+	users, err := loadDatabaseUsers(db, false)
+	if err != nil {
+		db.Close()
+		lock.release()
+		log.Error().Err(err).Msg("Error loading users")
+		return nil, err
+	}
+
+	deleted, err := loadDatabaseUsers(db, true)
+	if err != nil {
+		db.Close()
+		lock.release()
+		log.Error().Err(err).Msg("Error loading deleted users")
+		return nil, err
+	}
+
+	repo := &SQLiteRepo{
+		db:      db,
+		users:   users,
+		deleted: deleted,
+		lock:    lock,
+	}
+
+	// Data repaired on load is saved right away so the new IDs stay stable
+	repaired := false
+	for _, user := range users {
+		repaired = repairUser(user) || repaired
+	}
+	for _, user := range deleted {
+		repaired = repairUser(user) || repaired
+	}
+	if repaired {
+		if err := repo.PersistUserData(); err != nil {
+			repo.Close()
+			return nil, err
+		}
+	}
+
+	return repo, nil
+}
+
+// GetUser gets an user's data from the map.
+func (r *SQLiteRepo) GetUser(userName string) *domain.User {
+	return r.users[userName]
+}
+
+// AddUser add an user's data to the map.
+func (r *SQLiteRepo) AddUser(user *domain.User) error {
+	r.users[user.UserName] = user
+	return nil
+}
+
+// MoveUserToDeleted moves a user's data to the deleted map.
+func (r *SQLiteRepo) MoveUserToDeleted(userID string) error {
+	r.deleted[userID] = r.users[userID]
+	delete(r.users, userID)
+	return nil
+}
+
+// PersistUserData saves all user data to the database in a single transaction.
+func (r *SQLiteRepo) PersistUserData() error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, user := range r.users {
+		if err := saveDatabaseUser(tx, user, false); err != nil {
+			return err
+		}
+	}
+
+	for _, user := range r.deleted {
+		if err := saveDatabaseUser(tx, user, true); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("Error saving user data to database")
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	log.Info().Int("users", len(r.users)).Int("deleted", len(r.deleted)).Msg("User data saved successfully")
+	return nil
+}
+
+// Close closes the database and releases its lock.
+func (r *SQLiteRepo) Close() error {
+	err := r.db.Close()
+	if lockErr := r.lock.release(); err == nil {
+		err = lockErr
+	}
+	return err
+}
+
+// MigrateFilesToSQLite imports every user stored in the JSON files of the data
+// directory, deleted users included, into the database. The data directory stays locked
+// during the import, since loading the files repairs them. It returns the number of users imported.
+func MigrateFilesToSQLite(cfg config.Config) (int, error) {
+	// Opening the file store would create an empty data directory and import nothing
+	if info, err := os.Stat(cfg.DataDir); err != nil || !info.IsDir() {
+		return 0, fmt.Errorf("no data directory to import at %s, set it with --data-dir or data_dir in the config file", cfg.DataDir)
+	}
+
+	files, err := NewFileRepo(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer files.Close()
+
+	repo, err := NewSQLiteRepo(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer repo.Close()
+
+	for name, user := range files.users {
+		repo.users[name] = user
+	}
+	for name, user := range files.deleted {
+		delete(repo.users, name)
+		repo.deleted[name] = user
+	}
+
+	if err := repo.PersistUserData(); err != nil {
+		return 0, err
+	}
+
+	return len(files.users) + len(files.deleted), nil
+}
+
+// openDatabase opens the database and brings its schema up to date.
+func openDatabase(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", dbPath))
+	if err != nil {
+		log.Error().Err(err).Str("file", dbPath).Msg("Error opening database")
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	if err := migrateDatabase(db); err != nil {
+		db.Close()
+		log.Error().Err(err).Str("file", dbPath).Msg("Error migrating database")
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// migrateDatabase applies the migrations the database has not seen yet.
+func migrateDatabase(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("error starting migration: %w", err)
+		}
+
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error applying migration %d: %w", i+1, err)
+		}
+
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error updating schema version: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error committing migration %d: %w", i+1, err)
+		}
+		log.Info().Int("version", i+1).Msg("Database schema migrated")
+	}
+
+	return nil
+}
+
+// loadDatabaseUsers loads the active or deleted users with their loans and payments.
+func loadDatabaseUsers(db *sql.DB, deleted bool) (map[string]*domain.User, error) {
+	users := make(map[string]*domain.User)
+
+	rows, err := db.Query("SELECT user_name, last_loan_id FROM users WHERE deleted = ?", deleted)
+	if err != nil {
+		return users, fmt.Errorf("error reading users: %w", err)
+	}
+
+	var loaded []domain.User
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.UserName, &user.LastLoanID); err != nil {
+			rows.Close()
+			return users, fmt.Errorf("error reading users: %w", err)
+		}
+		loaded = append(loaded, user)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return users, fmt.Errorf("error reading users: %w", err)
+	}
+
+	for _, stored := range loaded {
+		user := domain.NewUser(stored.UserName)
+		user.LastLoanID = stored.LastLoanID
+		if user.Loans, err = loadDatabaseLoans(db, user.UserName); err != nil {
+			return users, err
+		}
+		users[user.UserName] = &user
+	}
+
+	log.Info().Int("count", len(users)).Bool("deleted", deleted).Msg("All users loaded successfully")
+	return users, nil
+}
+
+// loadDatabaseLoans loads the loans of a user in their original order.
+func loadDatabaseLoans(db *sql.DB, userName string) ([]domain.Loan, error) {
+	rows, err := db.Query(`SELECT id, loan_id, loan_name, status, amount, interest, start_date, due_day, term, penalty_grace_days, penalty_late_fee, penalty_rate, monthly_payment, time_paid_off,
+		legacy_balance
+		FROM loans WHERE user_name = ? ORDER BY position`, userName)
+	if err != nil {
+		return nil, fmt.Errorf("error reading loans: %w", err)
+	}
+	defer rows.Close()
+
+	loans := []domain.Loan{}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var loan domain.Loan
+		var penalty domain.PenaltyRule
+		var legacyBalance sql.Null[domain.Money]
+		err := rows.Scan(&id, &loan.LoanID, &loan.LoanName, &loan.Status, &loan.Amount, &loan.Interest, &loan.StartDate, &loan.DueDay, &loan.Term,
+			&penalty.GraceDays, &penalty.LateFee, &penalty.PenaltyRate, &loan.MonthlyPayment, &loan.TimePaidOff, &legacyBalance)
+		if err != nil {
+			return nil, fmt.Errorf("error reading loans: %w", err)
+		}
+		// Only the loans stored before the ledger have a balance, the new rows leave it NULL
+		if legacyBalance.Valid {
+			loan.LegacyBalance = &legacyBalance.V
+		}
+		// A rule that charges nothing is stored for the loans without penalties
+		if penalty.LateFee != 0 || penalty.PenaltyRate != 0 {
+			loan.Penalty = &penalty
+		}
+		loans = append(loans, loan)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading loans: %w", err)
+	}
+	rows.Close()
+
+	for i := range loans {
+		if loans[i].Ledger, err = loadDatabaseLedger(db, ids[i]); err != nil {
+			return nil, err
+		}
+		if loans[i].RateChanges, err = loadDatabaseRateChanges(db, ids[i]); err != nil {
+			return nil, err
+		}
+		if loans[i].Fees, err = loadDatabaseFees(db, ids[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return loans, nil
+}
+
+// loadDatabaseLedger loads the ledger of a loan in its original order.
+func loadDatabaseLedger(db *sql.DB, loanRowID int64) ([]domain.Transaction, error) {
+	rows, err := db.Query(`SELECT transaction_id, type, installment, date_time, description, amount
+		FROM ledger WHERE loan = ? ORDER BY position`, loanRowID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading ledger: %w", err)
+	}
+	defer rows.Close()
+
+	ledger := []domain.Transaction{}
+	for rows.Next() {
+		var transaction domain.Transaction
+		if err := rows.Scan(&transaction.ID, &transaction.Type, &transaction.Installment, &transaction.DateTime, &transaction.Description, &transaction.Amount); err != nil {
+			return nil, fmt.Errorf("error reading ledger: %w", err)
+		}
+		ledger = append(ledger, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading ledger: %w", err)
+	}
+
+	return ledger, nil
+}
+
+// loadDatabaseRateChanges loads the interest rate changes of a loan, oldest first.
+func loadDatabaseRateChanges(db *sql.DB, loanRowID int64) ([]domain.RateChange, error) {
+	rows, err := db.Query(`SELECT effective_date, rate, monthly_payment
+		FROM rate_changes WHERE loan = ? ORDER BY position`, loanRowID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rate changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []domain.RateChange
+	for rows.Next() {
+		var change domain.RateChange
+		if err := rows.Scan(&change.EffectiveDate, &change.Rate, &change.MonthlyPayment); err != nil {
+			return nil, fmt.Errorf("error reading rate changes: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rate changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// loadDatabaseFees loads the fees of a loan in their original order.
+func loadDatabaseFees(db *sql.DB, loanRowID int64) ([]domain.Fee, error) {
+	rows, err := db.Query(`SELECT name, amount, recurring FROM fees WHERE loan = ? ORDER BY position`, loanRowID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fees: %w", err)
+	}
+	defer rows.Close()
+
+	var fees []domain.Fee
+	for rows.Next() {
+		var fee domain.Fee
+		if err := rows.Scan(&fee.Name, &fee.Amount, &fee.Recurring); err != nil {
+			return nil, fmt.Errorf("error reading fees: %w", err)
+		}
+		fees = append(fees, fee)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading fees: %w", err)
+	}
+
+	return fees, nil
+}
+
+// saveDatabaseUser replaces the stored loans and payments of a user with the ones in memory.
+func saveDatabaseUser(tx *sql.Tx, user *domain.User, deleted bool) error {
+	_, err := tx.Exec(`INSERT INTO users (user_name, deleted, last_loan_id) VALUES (?, ?, ?)
+		ON CONFLICT (user_name) DO UPDATE SET deleted = excluded.deleted, last_loan_id = excluded.last_loan_id`,
+		user.UserName, deleted, user.LastLoanID)
+	if err != nil {
+		log.Error().Err(err).Str("user", user.UserName).Msg("Error saving user")
+		return fmt.Errorf("error saving user: %w", err)
+	}
+
+	// Ledger entries, rate changes and fees are removed by the ON DELETE CASCADE of the loans
+	if _, err := tx.Exec("DELETE FROM loans WHERE user_name = ?", user.UserName); err != nil {
+		return fmt.Errorf("error deleting loans: %w", err)
+	}
+
+	for position, loan := range user.Loans {
+		var penalty domain.PenaltyRule
+		if loan.Penalty != nil {
+			penalty = *loan.Penalty
+		}
+
+		result, err := tx.Exec(`INSERT INTO loans (user_name, position, loan_id, loan_name, status, amount, interest, start_date,
+			due_day, term, penalty_grace_days, penalty_late_fee, penalty_rate, monthly_payment, time_paid_off)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			user.UserName, position, loan.LoanID, loan.LoanName, loan.Status, loan.Amount, loan.Interest, loan.StartDate, loan.DueDay, loan.Term,
+			penalty.GraceDays, penalty.LateFee, penalty.PenaltyRate, loan.MonthlyPayment, loan.TimePaidOff)
+		if err != nil {
+			log.Error().Err(err).Str("user", user.UserName).Str("loan_id", loan.LoanID).Msg("Error saving loan")
+			return fmt.Errorf("error saving loan: %w", err)
+		}
+
+		loanRowID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("error saving loan: %w", err)
+		}
+
+		for entryPosition, entry := range loan.Ledger {
+			_, err := tx.Exec(`INSERT INTO ledger (loan, position, transaction_id, type, installment, date_time, description, amount)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				loanRowID, entryPosition, entry.ID, entry.Type, entry.Installment, entry.DateTime, entry.Description, entry.Amount)
+			if err != nil {
+				log.Error().Err(err).Str("user", user.UserName).Str("loan_id", loan.LoanID).Msg("Error saving ledger entry")
+				return fmt.Errorf("error saving ledger entry: %w", err)
+			}
+		}
+
+		for changePosition, change := range loan.RateChanges {
+			_, err := tx.Exec(`INSERT INTO rate_changes (loan, position, effective_date, rate, monthly_payment)
+				VALUES (?, ?, ?, ?, ?)`,
+				loanRowID, changePosition, change.EffectiveDate, change.Rate, change.MonthlyPayment)
+			if err != nil {
+				log.Error().Err(err).Str("user", user.UserName).Str("loan_id", loan.LoanID).Msg("Error saving rate change")
+				return fmt.Errorf("error saving rate change: %w", err)
+			}
+		}
+
+		for feePosition, fee := range loan.Fees {
+			_, err := tx.Exec(`INSERT INTO fees (loan, position, name, amount, recurring) VALUES (?, ?, ?, ?, ?)`,
+				loanRowID, feePosition, fee.Name, fee.Amount, fee.Recurring)
+			if err != nil {
+				log.Error().Err(err).Str("user", user.UserName).Str("loan_id", loan.LoanID).Msg("Error saving fee")
+				return fmt.Errorf("error saving fee: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,563 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+	"github.com/zapisanchez/loanMgr/internal/core/services"
+)
+
+// Structure of the body to create a user
+type createUserRequest struct {
+	UserName string `json:"user_name"`
+}
+
+// Structure of the body to create a loan
+type createLoanRequest struct {
+	LoanName       string       `json:"loan_name"`
+	Amount         domain.Money `json:"amount"`
+	Interest       float64      `json:"interest"`
+	MonthlyPayment domain.Money `json:"monthly_payment"`
+	Fees           []domain.Fee `json:"fees"`       // Optional upfront and recurring fees
+	StartDate      string       `json:"start_date"` // YYYY-MM-DD or RFC3339, defaults to now
+	DueDay         int          `json:"due_day"`    // Day of the month the installments are due, defaults to the day of the start date
+	Term           int          `json:"term"`       // Number of monthly installments, zero until paid off
+}
+
+// Structure of the body to set the payment calendar of a loan
+type loanCalendarRequest struct {
+	StartDate string `json:"start_date"` // YYYY-MM-DD or RFC3339, keeps the current one when empty
+	DueDay    int    `json:"due_day"`    // Day of the month the installments are due, defaults to the day of the start date
+	Term      int    `json:"term"`       // Number of monthly installments, zero until paid off
+}
+
+// Structure of the body to change the status of a loan
+type loanStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// Structure of the body to add a payment
+type addPaymentRequest struct {
+	Amount      domain.Money `json:"amount"`
+	Description string       `json:"description"`
+	DateTime    string       `json:"date_time"`   // YYYY-MM-DD or RFC3339, defaults to now
+	Overpayment string       `json:"overpayment"` // reject, cap or credit, defaults to reject
+}
+
+// Structure of the body to add a fee, an adjustment or a refund to the ledger
+type addTransactionRequest struct {
+	Type        string       `json:"type"` // fee, adjustment or refund
+	Amount      domain.Money `json:"amount"`
+	Description string       `json:"description"`
+	DateTime    string       `json:"date_time"` // YYYY-MM-DD or RFC3339, defaults to now
+}
+
+// Structure of the body to record a change of the interest rate
+type changeRateRequest struct {
+	EffectiveDate  string       `json:"effective_date"`  // YYYY-MM-DD or RFC3339, defaults to now
+	Rate           float64      `json:"rate"`            // New annual interest rate in percent
+	MonthlyPayment domain.Money `json:"monthly_payment"` // New monthly payment, zero keeps the current one
+}
+
+// Structure of the body to modify a payment, the fields left out are not changed
+type modifyPaymentRequest struct {
+	Amount      *domain.Money `json:"amount"`
+	Description *string       `json:"description"`
+	DateTime    *string       `json:"date_time"` // YYYY-MM-DD or RFC3339
+}
+
+// Structure of the body to set the charges of a late installment
+type penaltyRequest struct {
+	GraceDays   int          `json:"grace_days"`   // Days after the due date before an installment is late
+	LateFee     domain.Money `json:"late_fee"`     // Fixed fee charged once for each late installment
+	PenaltyRate float64      `json:"penalty_rate"` // Annual rate in percent charged on the unpaid part
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) error {
+	var req createUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	// The user name is used as a file name by the file store
+	if req.UserName == "" || strings.ContainsAny(req.UserName, `/\`) || strings.HasPrefix(req.UserName, ".") {
+		return newAPIError(http.StatusBadRequest, "invalid user_name %q", req.UserName)
+	}
+
+	user, err := s.srvcs.CreateUser(req.UserName)
+	if err != nil {
+		return err
+	}
+	if err := s.persist(); err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusCreated, user)
+	return nil
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.viewUser(r)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, user)
+	return nil
+}
+
+func (s *Server) getSummary(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.viewUser(r)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, services.Summarize(user.Loans))
+	return nil
+}
+
+func (s *Server) listLoans(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.viewUser(r)
+	if err != nil {
+		return err
+	}
+
+	archived := false
+	if value := r.URL.Query().Get("archived"); value != "" {
+		if archived, err = strconv.ParseBool(value); err != nil {
+			return newAPIError(http.StatusBadRequest, "invalid archived parameter %q", value)
+		}
+	}
+
+	loans := user.CurrentLoans()
+	if archived {
+		loans = user.ArchivedLoans()
+	}
+
+	writeJSON(w, http.StatusOK, loans)
+	return nil
+}
+
+func (s *Server) createLoan(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.lookupUser(r)
+	if err != nil {
+		return err
+	}
+
+	var req createLoanRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	startDate, err := domain.ParsePaymentDate(req.StartDate)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "%v", err)
+	}
+	if err := domain.ValidateCalendar(startDate, req.DueDay, req.Term); err != nil {
+		return err
+	}
+
+	var loan *domain.Loan
+	err = s.update(user, func() error {
+		if loan, err = s.srvcs.CreateLoan(user.UserName, req.LoanName, req.Amount, req.Interest, req.MonthlyPayment, req.Fees...); err != nil {
+			return err
+		}
+		return s.srvcs.SetLoanCalendar(user.UserName, loan.LoanID, startDate, req.DueDay, req.Term)
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusCreated, loan)
+	return nil
+}
+
+func (s *Server) getLoan(w http.ResponseWriter, r *http.Request) error {
+	_, loan, err := s.viewLoan(r)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, loan)
+	return nil
+}
+
+func (s *Server) setLoanStatus(w http.ResponseWriter, r *http.Request) error {
+	user, loan, err := s.lookupLoan(r)
+	if err != nil {
+		return err
+	}
+
+	var req loanStatusRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	status, err := domain.ParseLoanStatus(req.Status)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "%v", err)
+	}
+
+	err = s.update(user, func() error {
+		return s.srvcs.ChangeLoanStatus(user.UserName, loan.LoanID, status)
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, loan)
+	return nil
+}
+
+func (s *Server) getSchedule(w http.ResponseWriter, r *http.Request) error {
+	_, loan, err := s.viewLoan(r)
+	if err != nil {
+		return err
+	}
+
+	schedule, err := loan.AmortizationSchedule(time.Now())
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, schedule)
+	return nil
+}
+
+func (s *Server) listPayments(w http.ResponseWriter, r *http.Request) error {
+	_, loan, err := s.viewLoan(r)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, loan.GetPayments())
+	return nil
+}
+
+func (s *Server) getLedger(w http.ResponseWriter, r *http.Request) error {
+	_, loan, err := s.viewLoan(r)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, loan.Replay())
+	return nil
+}
+
+func (s *Server) addTransaction(w http.ResponseWriter, r *http.Request) error {
+	user, loan, err := s.lookupLoan(r)
+	if err != nil {
+		return err
+	}
+
+	var req addTransactionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	transactionType, err := domain.ParseTransactionType(req.Type)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "%v", err)
+	}
+
+	dateTime, err := domain.ParsePaymentDate(req.DateTime)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "%v", err)
+	}
+
+	transaction := domain.Transaction{Type: transactionType, Amount: req.Amount, Description: req.Description, DateTime: dateTime}
+	err = s.update(user, func() error {
+		_, err := s.srvcs.AddTransactionToLoan(user.UserName, loan.LoanID, transaction)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusCreated, loan.Replay())
+	return nil
+}
+
+func (s *Server) addPayment(w http.ResponseWriter, r *http.Request) error {
+	user, loan, err := s.lookupLoan(r)
+	if err != nil {
+		return err
+	}
+
+	var req addPaymentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	paymentDate, err := domain.ParsePaymentDate(req.DateTime)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "%v", err)
+	}
+
+	mode := domain.OverpaymentReject
+	if req.Overpayment != "" {
+		if mode, err = domain.ParseOverpaymentMode(req.Overpayment); err != nil {
+			return newAPIError(http.StatusBadRequest, "%v", err)
+		}
+	}
+
+	payment := domain.Transaction{Amount: req.Amount, Description: req.Description, DateTime: paymentDate}
+	err = s.update(user, func() error {
+		_, err := s.srvcs.AddPaymentToLoan(user.UserName, loan.LoanID, payment, mode)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusCreated, loan)
+	return nil
+}
+
+func (s *Server) changeRate(w http.ResponseWriter, r *http.Request) error {
+	user, loan, err := s.lookupLoan(r)
+	if err != nil {
+		return err
+	}
+
+	var req changeRateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	effectiveDate, err := domain.ParsePaymentDate(req.EffectiveDate)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "%v", err)
+	}
+
+	change := domain.RateChange{EffectiveDate: effectiveDate, Rate: req.Rate, MonthlyPayment: req.MonthlyPayment}
+	var impact services.RateChangeImpact
+	err = s.update(user, func() error {
+		impact, err = s.srvcs.ChangeLoanRate(user.UserName, loan.LoanID, change)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusCreated, impact)
+	return nil
+}
+
+func (s *Server) setLoanCalendar(w http.ResponseWriter, r *http.Request) error {
+	user, loan, err := s.lookupLoan(r)
+	if err != nil {
+		return err
+	}
+
+	var req loanCalendarRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	startDate := ""
+	if req.StartDate != "" {
+		if startDate, err = domain.ParsePaymentDate(req.StartDate); err != nil {
+			return newAPIError(http.StatusBadRequest, "%v", err)
+		}
+	}
+
+	err = s.update(user, func() error {
+		return s.srvcs.SetLoanCalendar(user.UserName, loan.LoanID, startDate, req.DueDay, req.Term)
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, loan)
+	return nil
+}
+
+func (s *Server) setPenalty(w http.ResponseWriter, r *http.Request) error {
+	user, loan, err := s.lookupLoan(r)
+	if err != nil {
+		return err
+	}
+
+	var req penaltyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	rule := domain.PenaltyRule{GraceDays: req.GraceDays, LateFee: req.LateFee, PenaltyRate: req.PenaltyRate}
+	err = s.update(user, func() error {
+		return s.srvcs.SetPenaltyRule(user.UserName, loan.LoanID, &rule)
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, loan)
+	return nil
+}
+
+func (s *Server) removePenalty(w http.ResponseWriter, r *http.Request) error {
+	user, loan, err := s.lookupLoan(r)
+	if err != nil {
+		return err
+	}
+
+	err = s.update(user, func() error {
+		return s.srvcs.SetPenaltyRule(user.UserName, loan.LoanID, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, loan)
+	return nil
+}
+
+func (s *Server) getDueStatuses(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.viewUser(r)
+	if err != nil {
+		return err
+	}
+
+	statuses := services.DueStatuses(user.CurrentLoans(), time.Now())
+	if statuses == nil {
+		statuses = []services.DueStatus{}
+	}
+
+	writeJSON(w, http.StatusOK, statuses)
+	return nil
+}
+
+func (s *Server) modifyPayment(w http.ResponseWriter, r *http.Request) error {
+	user, loan, err := s.lookupLoan(r)
+	if err != nil {
+		return err
+	}
+
+	paymentID := r.PathValue("payment")
+	payment := loan.GetPayment(paymentID)
+	if payment == nil {
+		return fmt.Errorf("%w: %q", services.ErrPaymentNotFound, paymentID)
+	}
+
+	var req modifyPaymentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	amount, description, dateTime := payment.Amount, payment.Description, ""
+	if req.Amount != nil {
+		amount = *req.Amount
+	}
+	if req.Description != nil {
+		description = *req.Description
+	}
+	if req.DateTime != nil {
+		if dateTime, err = domain.ParsePaymentDate(*req.DateTime); err != nil {
+			return newAPIError(http.StatusBadRequest, "%v", err)
+		}
+	}
+
+	err = s.update(user, func() error {
+		return s.srvcs.ModifyPaymentFromLoan(user.UserName, loan.LoanID, paymentID, amount, description, dateTime)
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, loan)
+	return nil
+}
+
+func (s *Server) removePayment(w http.ResponseWriter, r *http.Request) error {
+	user, loan, err := s.lookupLoan(r)
+	if err != nil {
+		return err
+	}
+
+	err = s.update(user, func() error {
+		return s.srvcs.RemovePaymentFromLoan(user.UserName, loan.LoanID, r.PathValue("payment"))
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, loan)
+	return nil
+}
+
+// lookupUser returns the user named in the request path, for the requests that change it.
+func (s *Server) lookupUser(r *http.Request) (*domain.User, error) {
+	userName := r.PathValue("user")
+	user := s.srvcs.GetUser(userName)
+	if user == nil {
+		return nil, fmt.Errorf("%w: %q", services.ErrUserNotFound, userName)
+	}
+	return user, nil
+}
+
+// viewUser returns a copy of the user named in the request path with the charges of the late
+// installments of their loans up to date, for the requests that only read it. The charges are
+// not saved, the requests that change a loan post its own.
+func (s *Server) viewUser(r *http.Request) (*domain.User, error) {
+	user, err := s.lookupUser(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.srvcs.UserWithPenalties(user.UserName, time.Now())
+}
+
+// lookupLoan returns the user and the loan named in the request path.
+func (s *Server) lookupLoan(r *http.Request) (*domain.User, *domain.Loan, error) {
+	user, err := s.lookupUser(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return findLoan(user, r)
+}
+
+// viewLoan returns a copy of the user and the loan named in the request path, like viewUser.
+func (s *Server) viewLoan(r *http.Request) (*domain.User, *domain.Loan, error) {
+	user, err := s.viewUser(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return findLoan(user, r)
+}
+
+// findLoan returns the loan of the user named in the request path.
+func findLoan(user *domain.User, r *http.Request) (*domain.User, *domain.Loan, error) {
+	loanID := r.PathValue("loan")
+	loan := user.GetLoan(loanID)
+	if loan == nil {
+		return nil, nil, fmt.Errorf("%w: %q", services.ErrLoanNotFound, loanID)
+	}
+	return user, loan, nil
+}
+
+// update applies the change of the request to the user and saves it. When the change fails or
+// cannot be saved the user is restored as it was, or the next request would save the change.
+func (s *Server) update(user *domain.User, change func() error) error {
+	previous := user.Clone()
+	if err := change(); err != nil {
+		*user = previous
+		return err
+	}
+	if err := s.persist(); err != nil {
+		*user = previous
+		return err
+	}
+	return nil
+}
+
+// persist saves the changes made by the request.
+func (s *Server) persist() error {
+	if err := s.srvcs.Persist(); err != nil {
+		return fmt.Errorf("error saving data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/core/services"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxBodySize limits the size of the request bodies.
+const maxBodySize = 1 << 20
+
+// Server exposes the user service as a JSON API over HTTP.
+type Server struct {
+	srvcs *services.UserService
+	mux   *http.ServeMux
+
+	// The service and the domain are not safe for concurrent use, requests are served one at a time
+	mu sync.Mutex
+}
+
+// NewServer creates the HTTP handler of the API.
+func NewServer(srvcs *services.UserService) *Server {
+	s := &Server{
+		srvcs: srvcs,
+		mux:   http.NewServeMux(),
+	}
+	s.routes()
+	return s
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("POST /users", s.handle(s.createUser))
+	s.mux.HandleFunc("GET /users/{user}", s.handle(s.getUser))
+	s.mux.HandleFunc("GET /users/{user}/summary", s.handle(s.getSummary))
+	s.mux.HandleFunc("GET /users/{user}/due", s.handle(s.getDueStatuses))
+
+	s.mux.HandleFunc("GET /users/{user}/loans", s.handle(s.listLoans))
+	s.mux.HandleFunc("POST /users/{user}/loans", s.handle(s.createLoan))
+	s.mux.HandleFunc("GET /users/{user}/loans/{loan}", s.handle(s.getLoan))
+	s.mux.HandleFunc("PUT /users/{user}/loans/{loan}/status", s.handle(s.setLoanStatus))
+	s.mux.HandleFunc("GET /users/{user}/loans/{loan}/schedule", s.handle(s.getSchedule))
+	s.mux.HandleFunc("POST /users/{user}/loans/{loan}/rates", s.handle(s.changeRate))
+	s.mux.HandleFunc("PUT /users/{user}/loans/{loan}/calendar", s.handle(s.setLoanCalendar))
+	s.mux.HandleFunc("PUT /users/{user}/loans/{loan}/penalty", s.handle(s.setPenalty))
+	s.mux.HandleFunc("DELETE /users/{user}/loans/{loan}/penalty", s.handle(s.removePenalty))
+
+	s.mux.HandleFunc("GET /users/{user}/loans/{loan}/payments", s.handle(s.listPayments))
+	s.mux.HandleFunc("POST /users/{user}/loans/{loan}/payments", s.handle(s.addPayment))
+	s.mux.HandleFunc("PATCH /users/{user}/loans/{loan}/payments/{payment}", s.handle(s.modifyPayment))
+	s.mux.HandleFunc("DELETE /users/{user}/loans/{loan}/payments/{payment}", s.handle(s.removePayment))
+
+	s.mux.HandleFunc("GET /users/{user}/loans/{loan}/ledger", s.handle(s.getLedger))
+	s.mux.HandleFunc("POST /users/{user}/loans/{loan}/ledger", s.handle(s.addTransaction))
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	s.mux.ServeHTTP(recorder, r)
+
+	log.Info().Str("method", r.Method).Str("path", r.URL.Path).Int("status", recorder.status).
+		Dur("duration", time.Since(start)).Msg("Request served")
+}
+
+// statusRecorder keeps the status code written by a handler, for the request log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// handlerFunc is an HTTP handler that returns the error to send to the client.
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// handle serializes the requests and writes the error returned by the handler.
+func (s *Server) handle(h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if err := h(w, r); err != nil {
+			status := statusFromError(err)
+			if status == http.StatusInternalServerError {
+				log.Error().Err(err).Str("method", r.Method).Str("path", r.URL.Path).Msg("Error serving request")
+			}
+			writeJSON(w, status, errorResponse{Error: err.Error()})
+		}
+	}
+}
+
+// Structure of the body sent with every error
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// apiError is an error with the HTTP status to answer with.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// newAPIError creates an error answered with the given status.
+func newAPIError(status int, format string, args ...any) error {
+	return &apiError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// statusFromError maps the errors of the handlers and the service to a status code.
+func statusFromError(err error) int {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.status
+	}
+
+	switch {
+	case errors.Is(err, services.ErrUserNotFound),
+		errors.Is(err, services.ErrLoanNotFound),
+		errors.Is(err, services.ErrPaymentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidAmount),
+		errors.Is(err, services.ErrInvalidRate),
+		errors.Is(err, services.ErrInvalidLoan),
+		errors.Is(err, services.ErrPaymentBeforeStart),
+		errors.Is(err, services.ErrPaymentTooLow):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrUserExists),
+		errors.Is(err, services.ErrLoanFullyPaid),
+		errors.Is(err, services.ErrOverpayment),
+		errors.Is(err, services.ErrLoanNotOpen),
+		errors.Is(err, services.ErrAutomaticEntry),
+		errors.Is(err, services.ErrInvalidTransition):
+		// The request is valid but conflicts with the current state of the data
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
+
+// writeJSON writes the value as the JSON body of the response.
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		log.Error().Err(err).Msg("Error writing response")
+	}
+}
+
+// decodeJSON reads the JSON body of the request into value, rejecting unknown fields.
+func decodeJSON(r *http.Request, value any) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(value); err != nil {
+		return newAPIError(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+	if decoder.More() {
+		return newAPIError(http.StatusBadRequest, "invalid request body: unexpected data after the JSON object")
+	}
+	return nil
+}
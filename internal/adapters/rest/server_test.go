@@ -0,0 +1,289 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+	"github.com/zapisanchez/loanMgr/internal/core/services"
+)
+
+// memoryRepo keeps the users in memory, and can fail to save them.
+type memoryRepo struct {
+	users      map[string]*domain.User
+	persistErr error
+	persisted  int
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{users: make(map[string]*domain.User)}
+}
+
+func (r *memoryRepo) GetUser(userName string) *domain.User {
+	return r.users[userName]
+}
+
+func (r *memoryRepo) AddUser(user *domain.User) error {
+	r.users[user.UserName] = user
+	return nil
+}
+
+func (r *memoryRepo) MoveUserToDeleted(userName string) error {
+	delete(r.users, userName)
+	return nil
+}
+
+func (r *memoryRepo) PersistUserData() error {
+	if r.persistErr != nil {
+		return r.persistErr
+	}
+	r.persisted++
+	return nil
+}
+
+// request sends a request to the server and returns the response.
+func request(t *testing.T, srv http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+// mustRequest sends a request and fails the test when it does not answer with the status.
+func mustRequest(t *testing.T, srv http.Handler, method, path, body string, status int) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := request(t, srv, method, path, body)
+	if rec.Code != status {
+		t.Fatalf("%s %s = %d, want %d: %s", method, path, rec.Code, status, rec.Body)
+	}
+	return rec
+}
+
+// decode reads the JSON body of a response.
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var value T
+	if err := json.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+		t.Fatalf("invalid response body %s: %v", rec.Body, err)
+	}
+	return value
+}
+
+// newTestServer creates a server with the user alice, an active loan 1 with a payment and a
+// closed loan 2, all through the API.
+func newTestServer(t *testing.T) (*Server, *memoryRepo) {
+	t.Helper()
+	repo := newMemoryRepo()
+	srv := NewServer(services.NewUserService(repo))
+
+	mustRequest(t, srv, "POST", "/users", `{"user_name": "alice"}`, http.StatusCreated)
+	mustRequest(t, srv, "POST", "/users/alice/loans",
+		`{"loan_name": "car", "amount": "10000", "interest": 6, "monthly_payment": "304.22", "start_date": "2026-01-10", "due_day": 5, "term": 36}`,
+		http.StatusCreated)
+	mustRequest(t, srv, "POST", "/users/alice/loans/1/payments", `{"amount": "304.22", "date_time": "2026-02-05"}`, http.StatusCreated)
+	mustRequest(t, srv, "POST", "/users/alice/loans",
+		`{"loan_name": "card", "amount": "3000", "interest": 19.9, "monthly_payment": "100"}`, http.StatusCreated)
+	mustRequest(t, srv, "PUT", "/users/alice/loans/2/status", `{"status": "closed"}`, http.StatusOK)
+	return srv, repo
+}
+
+func TestCreateUser(t *testing.T) {
+	srv, repo := newTestServer(t)
+
+	rec := mustRequest(t, srv, "POST", "/users", `{"user_name": "bob"}`, http.StatusCreated)
+	if user := decode[domain.User](t, rec); user.UserName != "bob" || len(user.Loans) != 0 {
+		t.Errorf("created user = %+v, want bob without loans", user)
+	}
+	if repo.GetUser("bob") == nil {
+		t.Error("bob was not added to the repository")
+	}
+
+	mustRequest(t, srv, "GET", "/users/bob", "", http.StatusOK)
+}
+
+func TestCreateLoan(t *testing.T) {
+	srv, repo := newTestServer(t)
+	persisted := repo.persisted
+
+	rec := mustRequest(t, srv, "POST", "/users/alice/loans",
+		`{"loan_name": "student", "amount": "6000", "interest": 3, "monthly_payment": "80", "start_date": "2026-03-01", "due_day": 10}`,
+		http.StatusCreated)
+
+	loan := decode[domain.Loan](t, rec)
+	if loan.LoanID != "3" || loan.LoanName != "student" || loan.DueDay != 10 {
+		t.Errorf("created loan = %s %s due on %d, want 3 student due on 10", loan.LoanID, loan.LoanName, loan.DueDay)
+	}
+	if balance := loan.OutstandingBalance(); balance != 600000 {
+		t.Errorf("balance of the new loan = %s, want 6000.00", balance)
+	}
+	if repo.persisted != persisted+1 {
+		t.Errorf("the new loan was saved %d times, want 1", repo.persisted-persisted)
+	}
+}
+
+func TestChangeNotStored(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"create loan", "POST", "/users/alice/loans", `{"loan_name": "student", "amount": "6000", "interest": 3, "monthly_payment": "80"}`},
+		{"add payment", "POST", "/users/alice/loans/1/payments", `{"amount": "304.22", "date_time": "2026-03-05"}`},
+		{"add fee", "POST", "/users/alice/loans/1/ledger", `{"type": "fee", "amount": "25", "date_time": "2026-03-01"}`},
+		{"change status", "PUT", "/users/alice/loans/1/status", `{"status": "closed"}`},
+		{"change rate", "POST", "/users/alice/loans/1/rates", `{"effective_date": "2026-06-01", "rate": 7.5}`},
+		{"set calendar", "PUT", "/users/alice/loans/1/calendar", `{"due_day": 20, "term": 48}`},
+		{"set penalty", "PUT", "/users/alice/loans/1/penalty", `{"grace_days": 5, "late_fee": "15"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, repo := newTestServer(t)
+			before, err := json.Marshal(repo.GetUser("alice"))
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+
+			repo.persistErr = errors.New("disk full")
+			mustRequest(t, srv, tt.method, tt.path, tt.body, http.StatusInternalServerError)
+
+			// The change is not kept in memory for the next request to save it
+			after, err := json.Marshal(repo.GetUser("alice"))
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(after) != string(before) {
+				t.Errorf("alice changed after a failed save:\n%s\nwant\n%s", after, before)
+			}
+		})
+	}
+}
+
+func TestPaymentChangeNotStored(t *testing.T) {
+	srv, repo := newTestServer(t)
+	payments := decode[[]domain.Transaction](t, mustRequest(t, srv, "GET", "/users/alice/loans/1/payments", "", http.StatusOK))
+
+	repo.persistErr = errors.New("disk full")
+	mustRequest(t, srv, "PATCH", "/users/alice/loans/1/payments/"+payments[0].ID, `{"amount": "100"}`, http.StatusInternalServerError)
+	mustRequest(t, srv, "DELETE", "/users/alice/loans/1/payments/"+payments[0].ID, "", http.StatusInternalServerError)
+
+	// The next change saves only itself
+	repo.persistErr = nil
+	mustRequest(t, srv, "POST", "/users/alice/loans/1/payments", `{"amount": "304.22", "date_time": "2026-03-05"}`, http.StatusCreated)
+
+	rec := mustRequest(t, srv, "GET", "/users/alice/loans/1/payments", "", http.StatusOK)
+	if got := decode[[]domain.Transaction](t, rec); len(got) != 2 || got[0].ID != payments[0].ID || got[0].Amount != payments[0].Amount {
+		t.Errorf("payments after failed changes = %+v, want %+v and the new one", got, payments[0])
+	}
+}
+
+func TestAddPayment(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := mustRequest(t, srv, "POST", "/users/alice/loans/1/payments", `{"amount": "304.22", "date_time": "2026-03-05"}`, http.StatusCreated)
+	loan := decode[struct {
+		TotalPaid domain.Money `json:"total_paid"`
+	}](t, rec)
+	if loan.TotalPaid != 60844 {
+		t.Errorf("total paid = %s, want 608.44", loan.TotalPaid)
+	}
+
+	rec = mustRequest(t, srv, "GET", "/users/alice/loans/1/payments", "", http.StatusOK)
+	if payments := decode[[]domain.Transaction](t, rec); len(payments) != 2 {
+		t.Errorf("the loan has %d payments, want 2", len(payments))
+	}
+}
+
+func TestRemovePayment(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	payments := decode[[]domain.Transaction](t, mustRequest(t, srv, "GET", "/users/alice/loans/1/payments", "", http.StatusOK))
+	mustRequest(t, srv, "DELETE", "/users/alice/loans/1/payments/"+payments[0].ID, "", http.StatusOK)
+
+	rec := mustRequest(t, srv, "GET", "/users/alice/loans/1", "", http.StatusOK)
+	if loan := decode[domain.Loan](t, rec); loan.OutstandingBalance() != 1000000 {
+		t.Errorf("balance after removing the payment = %s, want 10000.00", loan.OutstandingBalance())
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := mustRequest(t, srv, "GET", "/users/alice/loans/1/schedule", "", http.StatusOK)
+	schedule := decode[domain.Schedule](t, rec)
+	if len(schedule.Periods) == 0 {
+		t.Fatal("the schedule has no installments")
+	}
+
+	// The installments are due on the due day of the loan
+	for _, period := range schedule.Periods {
+		if period.DueDate.Day() != 5 || !period.DueDate.Equal(period.DueDate.Truncate(24*time.Hour)) {
+			t.Errorf("installment %d due %s, want the 5th at midnight", period.Number, period.DueDate.Format(time.RFC3339Nano))
+		}
+	}
+	if last := schedule.Periods[len(schedule.Periods)-1]; last.ClosingBalance != 0 {
+		t.Errorf("the last installment leaves %s, want 0", last.ClosingBalance)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := mustRequest(t, srv, "GET", "/users/alice/summary", "", http.StatusOK)
+	summary := decode[services.Summary](t, rec)
+	if summary.Loans != 2 || summary.ByStatus[domain.StatusActive] != 1 || summary.ByStatus[domain.StatusClosed] != 1 {
+		t.Errorf("summary counts %d loans by status %v, want 1 active and 1 closed", summary.Loans, summary.ByStatus)
+	}
+	if summary.TotalPaid != 30422 || summary.MonthlyPayment != 30422 {
+		t.Errorf("summary paid %s with a monthly payment of %s, want 304.22 and 304.22", summary.TotalPaid, summary.MonthlyPayment)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"missing user", "GET", "/users/bob", "", http.StatusNotFound},
+		{"missing user of a loan", "GET", "/users/bob/loans/1", "", http.StatusNotFound},
+		{"missing loan", "GET", "/users/alice/loans/99", "", http.StatusNotFound},
+		{"missing payment", "PATCH", "/users/alice/loans/1/payments/nope", `{"amount": "10"}`, http.StatusNotFound},
+		{"missing payment to remove", "DELETE", "/users/alice/loans/1/payments/nope", "", http.StatusNotFound},
+
+		{"invalid amount", "POST", "/users/alice/loans/1/payments", `{"amount": "0"}`, http.StatusBadRequest},
+		{"invalid loan", "POST", "/users/alice/loans", `{"loan_name": "x", "amount": "-5", "interest": 3, "monthly_payment": "80"}`, http.StatusBadRequest},
+		{"bad body", "POST", "/users/alice/loans/1/payments", `{"amount": `, http.StatusBadRequest},
+		{"unknown field", "POST", "/users/alice/loans/1/payments", `{"amount": "10", "tip": "5"}`, http.StatusBadRequest},
+		{"invalid user name", "POST", "/users", `{"user_name": "../etc"}`, http.StatusBadRequest},
+
+		{"existing user", "POST", "/users", `{"user_name": "alice"}`, http.StatusConflict},
+		{"overpayment", "POST", "/users/alice/loans/1/payments", `{"amount": "20000", "date_time": "2026-03-05"}`, http.StatusConflict},
+		{"loan not open", "POST", "/users/alice/loans/2/payments", `{"amount": "100"}`, http.StatusConflict},
+		{"automatic entry", "DELETE", "/users/alice/loans/1/payments/disbursement", "", http.StatusConflict},
+		{"invalid transition", "PUT", "/users/alice/loans/2/status", `{"status": "paid_off"}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newTestServer(t)
+
+			rec := request(t, srv, tt.method, tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.status, rec.Body)
+			}
+			if body := decode[errorResponse](t, rec); body.Error == "" {
+				t.Error("the error response has no message")
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+// appName is the directory used under the XDG config and data directories.
+const appName = "loanMgr"
+
+// legacyDataDir is the directory used by older versions, relative to the working directory.
+const legacyDataDir = "loan_data"
+
+// Config holds the settings of loanMgr. Each value is taken, from lowest to highest
+// priority, from the defaults, the config file, the environment and the command line flags.
+type Config struct {
+	DataDir      string `json:"data_dir"`      // Directory of the user files
+	DefaultUser  string `json:"default_user"`  // User selected when none is given
+	Currency     string `json:"currency"`      // Currency symbol shown next to the amounts
+	LogLevel     string `json:"log_level"`     // zerolog level, empty for the mode default
+	OutputFormat string `json:"output_format"` // Output format of the subcommands
+	Store        string `json:"store"`         // Storage backend: file or sqlite
+	DBPath       string `json:"db_path"`       // SQLite database, defaults to loans.db in the data directory
+}
+
+// Environment variables that override the config file
+const (
+	envConfig       = "LOANMGR_CONFIG"
+	envDataDir      = "LOANMGR_DATA_DIR"
+	envDefaultUser  = "LOANMGR_USER"
+	envCurrency     = "LOANMGR_CURRENCY"
+	envLogLevel     = "LOANMGR_LOG_LEVEL"
+	envOutputFormat = "LOANMGR_FORMAT"
+	envStore        = "LOANMGR_STORE"
+	envDBPath       = "LOANMGR_DB"
+)
+
+// Default returns the configuration used when nothing else is set.
+func Default() Config {
+	return Config{
+		Currency:     "€",
+		OutputFormat: "table",
+		Store:        "file",
+	}
+}
+
+// DefaultPath returns the path of the config file, $XDG_CONFIG_HOME/loanMgr/config.json.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appName, "config.json"), nil
+}
+
+// Flags holds the command line flags that override the configuration.
+type Flags struct {
+	fs           *flag.FlagSet
+	configPath   string
+	dataDir      string
+	defaultUser  string
+	currency     string
+	logLevel     string
+	outputFormat string
+	store        string
+	dbPath       string
+}
+
+// RegisterFlags defines the configuration flags on the flag set.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+	fs.StringVar(&f.configPath, "config", "", "path of the config file (default $XDG_CONFIG_HOME/loanMgr/config.json)")
+	fs.StringVar(&f.dataDir, "data-dir", "", "directory of the loan data")
+	fs.StringVar(&f.defaultUser, "default-user", "", "user selected when none is given")
+	fs.StringVar(&f.currency, "currency", "", "currency symbol shown next to the amounts")
+	fs.StringVar(&f.logLevel, "log-level", "", "log level: debug, info, warn or error")
+	fs.StringVar(&f.outputFormat, "output-format", "", "default output format of the subcommands")
+	fs.StringVar(&f.store, "store", "", "storage backend: file or sqlite")
+	fs.StringVar(&f.dbPath, "db", "", "path of the SQLite database used by --store sqlite")
+	return f
+}
+
+// Load builds the configuration once the flags have been parsed.
+func (f *Flags) Load() (Config, error) {
+	cfg := Default()
+
+	// Read the config file. It is optional unless its path was given explicitly.
+	path, explicit := f.configPath, true
+	if path == "" {
+		path = os.Getenv(envConfig)
+	}
+	if path == "" {
+		explicit = false
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			log.Warn().Err(err).Msg("Cannot find the config directory, using the defaults")
+		}
+	}
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			if explicit || !errors.Is(err, fs.ErrNotExist) {
+				return cfg, err
+			}
+		}
+	}
+
+	cfg.applyEnv()
+
+	// Only the flags set on the command line override the other sources
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "data-dir":
+			cfg.DataDir = f.dataDir
+		case "default-user":
+			cfg.DefaultUser = f.defaultUser
+		case "currency":
+			cfg.Currency = f.currency
+		case "log-level":
+			cfg.LogLevel = f.logLevel
+		case "output-format":
+			cfg.OutputFormat = f.outputFormat
+		case "store":
+			cfg.Store = f.store
+		case "db":
+			cfg.DBPath = f.dbPath
+		}
+	})
+
+	if err := cfg.resolvePaths(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// readFile overrides the configuration with the values present in the file.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	log.Debug().Str("file", path).Msg("Config file loaded")
+	return nil
+}
+
+// applyEnv overrides the configuration with the environment variables that are set.
+func (c *Config) applyEnv() {
+	for name, value := range map[string]*string{
+		envDataDir:      &c.DataDir,
+		envDefaultUser:  &c.DefaultUser,
+		envCurrency:     &c.Currency,
+		envLogLevel:     &c.LogLevel,
+		envOutputFormat: &c.OutputFormat,
+		envStore:        &c.Store,
+		envDBPath:       &c.DBPath,
+	} {
+		if env, ok := os.LookupEnv(name); ok && env != "" {
+			*value = env
+		}
+	}
+}
+
+// resolvePaths fills in the data directory and database when they were not configured.
+func (c *Config) resolvePaths() error {
+	if c.DataDir == "" {
+		dir, err := defaultDataDir()
+		if err != nil {
+			return err
+		}
+		c.DataDir = dir
+	}
+
+	if c.DBPath == "" {
+		c.DBPath = filepath.Join(c.DataDir, "loans.db")
+	}
+	return nil
+}
+
+// defaultDataDir returns $XDG_DATA_HOME/loanMgr. A loan_data directory left in the working
+// directory by older versions is not used, only reported so it can be configured.
+func defaultDataDir() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error finding the data directory: %w", err)
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(dataHome, appName)
+
+	if info, err := os.Stat(legacyDataDir); err == nil && info.IsDir() {
+		log.Warn().Str("dir", legacyDataDir).Str("using", dir).
+			Msg("Found the loan_data directory of an older version, set data_dir in the config file, --data-dir or " + envDataDir + " to keep using it")
+	}
+	return dir, nil
+}
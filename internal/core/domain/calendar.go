@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Structure for an installment of the payment calendar of a loan
+type Installment struct {
+	Number  int       `json:"number"`
+	DueDate time.Time `json:"due_date"`
+	Amount  Money     `json:"amount"` // Expected payment, the last installment only pays what is left
+}
+
+// ValidateCalendar checks the payment calendar of a loan: a valid start date, a due day between
+// 1 and 31 (or zero for the day of the start date) and a term that is zero (until paid off) or a
+// positive number of months.
+func ValidateCalendar(startDate string, dueDay, term int) error {
+	switch {
+	case startDate != "" && parseDateTime(startDate).IsZero():
+		return fmt.Errorf("%w: invalid start date %q, use YYYY-MM-DD or RFC3339", ErrInvalidLoan, startDate)
+	case dueDay < 0 || dueDay > 31:
+		return fmt.Errorf("%w: the due day must be between 1 and 31, got %d", ErrInvalidLoan, dueDay)
+	case term < 0 || term > maxSchedulePeriods:
+		return fmt.Errorf("%w: the term must be between 0 and %d months, got %d", ErrInvalidLoan, maxSchedulePeriods, term)
+	}
+	return nil
+}
+
+// SetCalendar sets the date the loan was granted, the day of the month its installments are due
+// and its term in months, zero when it runs until paid off. An empty start date keeps the current
+// one and a zero due day is the day of the start date. The loan is disbursed on the start date,
+// so the interest accrues from it.
+func (l *Loan) SetCalendar(startDate string, dueDay, term int) error {
+	if err := ValidateCalendar(startDate, dueDay, term); err != nil {
+		return err
+	}
+
+	if startDate != "" {
+		start := parseDateTime(startDate)
+		for _, payment := range l.GetPayments() {
+			if paid := parseDateTime(payment.DateTime); !paid.IsZero() && paid.Before(start) {
+				return fmt.Errorf("%w: the start date %s is after the payment of %s", ErrInvalidLoan,
+					start.Format(time.DateOnly), paid.Format(time.DateOnly))
+			}
+		}
+		l.StartDate = start.Format(time.RFC3339)
+		if disbursement := l.GetPayment(disbursementID); disbursement != nil {
+			disbursement.DateTime = l.StartDate
+		}
+	}
+
+	if dueDay == 0 {
+		start := l.StartTime()
+		if start.IsZero() {
+			start = time.Now()
+		}
+		dueDay = start.Day()
+	}
+
+	l.DueDay = dueDay
+	l.Term = term
+	if err := l.setLedger(l.Ledger, true); err != nil {
+		return err
+	}
+
+	log.Info().Str("loan_id", l.LoanID).Str("start_date", l.StartDate).Int("due_day", dueDay).Int("term", term).Msg("Payment calendar set")
+	return nil
+}
+
+// HasCalendar reports whether the loan has a start date and a due day, so its installments
+// can be tracked.
+func (l *Loan) HasCalendar() bool {
+	return l.DueDay > 0 && !l.StartTime().IsZero()
+}
+
+// StartTime returns the date the loan was granted, or the zero time when it is unknown.
+func (l *Loan) StartTime() time.Time {
+	return parseDateTime(l.StartDate)
+}
+
+// DueDate returns the due date of the given installment, counting from 1. The first installment
+// is due the month after the start date, and in shorter months the due day moves to the last day
+// of the month. It returns the zero time when the loan has no calendar.
+func (l *Loan) DueDate(installment int) time.Time {
+	if !l.HasCalendar() {
+		return time.Time{}
+	}
+
+	return monthDay(l.StartTime(), installment, l.DueDay)
+}
+
+// dueDatesFrom returns the due date of the nth installment after the given date, counting from 1.
+// A loan with a calendar follows its due dates, an installment due on the date itself is the
+// first one. The other loans are due every month on the day of the month of the date.
+func (l *Loan) dueDatesFrom(from time.Time) func(n int) time.Time {
+	if !l.HasCalendar() {
+		return func(n int) time.Time { return monthDay(from, n, from.Day()) }
+	}
+
+	today := monthDay(from, 0, from.Day())
+	first := 1
+	for l.DueDate(first).Before(today) {
+		first++
+	}
+	return func(n int) time.Time { return l.DueDate(first + n - 1) }
+}
+
+// monthDay returns the given day of the month that is the number of months after the date, at
+// midnight. In shorter months the day moves to the last day of the month.
+func monthDay(date time.Time, months, day int) time.Time {
+	month := time.Date(date.Year(), date.Month()+time.Month(months), 1, 0, 0, 0, 0, date.Location())
+	lastDay := month.AddDate(0, 1, -1).Day()
+	return month.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+// MaturityDate returns the due date of the last installment of the term, or the zero time when
+// the loan has no calendar or no term.
+func (l *Loan) MaturityDate() time.Time {
+	if l.Term == 0 {
+		return time.Time{}
+	}
+	return l.DueDate(l.Term)
+}
+
+// Installments returns the payment calendar of the loan: the due date and the expected amount
+// of every installment of its original schedule. The last installment, at the end of the term
+// when the loan has one, is the amount that pays off the loan on its due date. It returns nil
+// when the loan has no calendar.
+func (l *Loan) Installments() ([]Installment, error) {
+	if !l.HasCalendar() {
+		return nil, nil
+	}
+
+	schedule, err := l.OriginalSchedule()
+	if err != nil {
+		return nil, err
+	}
+
+	periods := schedule.Periods
+	if l.Term > 0 && len(periods) > l.Term {
+		periods = periods[:l.Term]
+	}
+
+	installments := make([]Installment, len(periods))
+	for i, period := range periods {
+		installments[i] = Installment{Number: period.Number, DueDate: l.DueDate(period.Number), Amount: period.Payment}
+	}
+
+	// The schedule charges a month of interest per installment and the ledger charges it by the
+	// day, so the last installment pays whatever the ones before leave, like the balance left at
+	// the end of the term
+	if n := len(installments); n > 0 {
+		installments[n-1].Amount = l.payoffOnSchedule(installments)
+	}
+
+	return installments, nil
+}
+
+// payoffOnSchedule returns what pays off the loan as it was granted on the due date of the last
+// of the installments, when every installment before it is paid on its due date.
+func (l *Loan) payoffOnSchedule(installments []Installment) Money {
+	last := len(installments) - 1
+
+	original := *l
+	original.Ledger = []Transaction{newDisbursement(l.Amount, l.StartDate)}
+	for _, installment := range installments[:last] {
+		original.Ledger = append(original.Ledger, Transaction{
+			Type:     TransactionPayment,
+			DateTime: installment.DueDate.Format(time.RFC3339),
+			Amount:   installment.Amount,
+		})
+	}
+
+	return max(original.PayoffAmount(installments[last].DueDate.Format(time.RFC3339)), 0)
+}
+
+// PaidUntil returns the sum of the payments made up to the given date, included.
+func (l *Loan) PaidUntil(date time.Time) Money {
+	var paid Money
+	for _, payment := range l.GetPayments() {
+		if !parseDateTime(payment.DateTime).After(date) {
+			paid += payment.Amount
+		}
+	}
+	return paid
+}
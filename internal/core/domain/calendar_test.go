@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInstallmentsPayOffLoan(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  string
+		rate    float64
+		payment string
+		term    int
+	}{
+		{"mortgage", "200000", 4.5, "1013.38", 360},
+		{"car loan", "10000", 6, "304.22", 36},
+		{"short term", "10000", 6, "304.22", 24}, // The balance left at the end of the term is due with the last installment
+		{"no term", "3000", 19.9, "100", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := NewLoan("1", tt.name, mustMoney(t, tt.amount), tt.rate, mustMoney(t, tt.payment))
+			if err := loan.SetCalendar("2026-01-15", 15, tt.term); err != nil {
+				t.Fatalf("SetCalendar: %v", err)
+			}
+
+			installments, err := loan.Installments()
+			if err != nil {
+				t.Fatalf("Installments: %v", err)
+			}
+			if tt.term > 0 && len(installments) != tt.term {
+				t.Errorf("the calendar has %d installments, want %d", len(installments), tt.term)
+			}
+
+			// Paying every installment on its due date pays off the loan, and the last one is not
+			// rejected as an overpayment
+			for _, installment := range installments {
+				payment := Transaction{Amount: installment.Amount, DateTime: installment.DueDate.Format(time.RFC3339)}
+				if _, err := loan.AddPayment(payment, OverpaymentReject); err != nil {
+					t.Fatalf("paying installment %d of %s: %v", installment.Number, installment.Amount, err)
+				}
+			}
+
+			if status := loan.GetStatus(); status != StatusPaidOff {
+				t.Errorf("status = %s, want %s", status, StatusPaidOff)
+			}
+			if balance, credit := loan.OutstandingBalance(), loan.Credit(); balance != 0 || credit != 0 {
+				t.Errorf("the loan ends with a balance of %s and a credit of %s, want 0", balance, credit)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "errors"
+
+// Errors returned by the loan methods. They are wrapped with the details of the failure,
+// so use errors.Is to check for them.
+var (
+	// ErrPaymentNotFound is returned when a loan has no payment with the given ID.
+	ErrPaymentNotFound = errors.New("payment not found")
+
+	// ErrLoanFullyPaid is returned when a payment is added to a loan with nothing left to pay.
+	ErrLoanFullyPaid = errors.New("loan fully paid")
+
+	// ErrOverpayment is returned when a payment is greater than what is left to pay.
+	ErrOverpayment = errors.New("payment is greater than the amount left to pay")
+
+	// ErrInvalidAmount is returned for amounts that are zero, negative or otherwise not allowed.
+	ErrInvalidAmount = errors.New("invalid amount")
+
+	// ErrInvalidRate is returned for interest rates that are negative or not a number.
+	ErrInvalidRate = errors.New("invalid interest rate")
+
+	// ErrInvalidLoan is returned for loans missing required data, like the name.
+	ErrInvalidLoan = errors.New("invalid loan")
+
+	// ErrPaymentBeforeStart is returned when a payment is dated before the loan was granted.
+	ErrPaymentBeforeStart = errors.New("payment dated before the loan start date")
+
+	// ErrPaymentTooLow is returned when the monthly payment does not cover the interest,
+	// so the loan would never be paid off.
+	ErrPaymentTooLow = errors.New("monthly payment too low")
+
+	// ErrAutomaticEntry is returned when changing by hand the disbursement, a late fee or a
+	// penalty interest of the ledger, since they are posted and reversed automatically.
+	ErrAutomaticEntry = errors.New("automatic ledger entry cannot be changed")
+)
@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Structure for a fee charged by the lender, like an opening fee, insurance or admin charges
+type Fee struct {
+	Name      string `json:"name"`
+	Amount    Money  `json:"amount"`
+	Recurring bool   `json:"recurring"` // Charged with every installment instead of once when the loan is granted
+}
+
+// ValidateFee checks the fee has a name and a positive amount.
+func ValidateFee(fee Fee) error {
+	switch {
+	case strings.TrimSpace(fee.Name) == "":
+		return fmt.Errorf("%w: the fee name cannot be empty", ErrInvalidLoan)
+	case fee.Amount <= 0:
+		return fmt.Errorf("%w: the fee %q must be greater than zero, got %s", ErrInvalidAmount, fee.Name, fee.Amount)
+	}
+	return nil
+}
+
+// AddFee records a fee of the loan. Fees are part of the cost of the loan but do not change
+// its balance.
+func (l *Loan) AddFee(fee Fee) error {
+	if err := ValidateFee(fee); err != nil {
+		return err
+	}
+
+	fee.Name = strings.TrimSpace(fee.Name)
+	l.Fees = append(l.Fees, fee)
+	return nil
+}
+
+// UpfrontFees returns the sum of the fees charged once when the loan is granted.
+func (l *Loan) UpfrontFees() Money {
+	var total Money
+	for _, fee := range l.Fees {
+		if !fee.Recurring {
+			total += fee.Amount
+		}
+	}
+	return total
+}
+
+// MonthlyFees returns the sum of the fees charged with every installment.
+func (l *Loan) MonthlyFees() Money {
+	var total Money
+	for _, fee := range l.Fees {
+		if fee.Recurring {
+			total += fee.Amount
+		}
+	}
+	return total
+}
+
+// OriginalSchedule returns the amortization schedule of the loan as it was granted, without
+// the payments made since, starting at the start date.
+func (l *Loan) OriginalSchedule() (Schedule, error) {
+	original := *l
+	original.Ledger = []Transaction{newDisbursement(l.Amount, l.StartDate)}
+
+	start := parseDateTime(l.StartDate)
+	if start.IsZero() {
+		start = time.Now()
+	}
+	return original.AmortizationSchedule(start)
+}
+
+// APR returns the annual percentage rate (TAE) in percent of the loan as it was granted: the
+// amount received minus the upfront fees, repaid with the installments plus the recurring fees.
+func (l *Loan) APR() (float64, error) {
+	schedule, err := l.OriginalSchedule()
+	if err != nil {
+		return 0, err
+	}
+
+	monthlyFees := l.MonthlyFees()
+	installments := make([]Money, len(schedule.Periods))
+	for i, period := range schedule.Periods {
+		installments[i] = period.Payment + monthlyFees
+	}
+
+	return APR(l.Amount-l.UpfrontFees(), installments)
+}
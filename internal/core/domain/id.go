@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// NewLoanID returns the next loan ID of the user. IDs come from a counter stored with
+// the user, so the ID of a removed loan is never given to a new one.
+func (u *User) NewLoanID() string {
+	// Users stored before the counter existed continue after their highest ID
+	for _, loan := range u.Loans {
+		if id, err := strconv.Atoi(loan.LoanID); err == nil && id > u.LastLoanID {
+			u.LastLoanID = id
+		}
+	}
+
+	u.LastLoanID++
+	return strconv.Itoa(u.LastLoanID)
+}
+
+// RepairLoanIDs gives a new ID to every loan that shares its ID with a previous loan of
+// the user, as older versions could generate. It returns the renumbered loans as a map
+// from the new ID to the old one.
+func (u *User) RepairLoanIDs() map[string]string {
+	renumbered := make(map[string]string)
+	seen := make(map[string]bool)
+
+	for i := range u.Loans {
+		loan := &u.Loans[i]
+		if loan.LoanID != "" && !seen[loan.LoanID] {
+			seen[loan.LoanID] = true
+			continue
+		}
+
+		oldID := loan.LoanID
+		loan.LoanID = u.NewLoanID()
+		seen[loan.LoanID] = true
+		renumbered[loan.LoanID] = oldID
+	}
+
+	return renumbered
+}
+
+// newPaymentID generates a unique payment ID: the creation time in base 36 followed by
+// random bytes, so IDs sort roughly by creation and never depend on the payment date.
+func newPaymentID() string {
+	random := make([]byte, 6)
+	if _, err := rand.Read(random); err != nil {
+		// crypto/rand does not fail on supported platforms, the nanoseconds keep the ID unique enough
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return strconv.FormatInt(time.Now().UnixMilli(), 36) + "-" + hex.EncodeToString(random)
+}
@@ -0,0 +1,369 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TransactionType tells what an entry of the loan ledger is.
+type TransactionType string
+
+const (
+	TransactionDisbursement    TransactionType = "disbursement"     // Amount lent, it opens the ledger
+	TransactionPayment         TransactionType = "payment"          // Money paid by the borrower
+	TransactionInterest        TransactionType = "interest"         // Interest accrued until a payment, posted by the replay
+	TransactionFee             TransactionType = "fee"              // Fee the lender adds to the balance
+	TransactionLateFee         TransactionType = "late_fee"         // Fixed fee charged for a late installment
+	TransactionPenaltyInterest TransactionType = "penalty_interest" // Interest charged on the unpaid part of a late installment
+	TransactionRateChange      TransactionType = "rate_change"      // New interest rate, posted by the replay from the rate changes
+	TransactionAdjustment      TransactionType = "adjustment"       // Correction of the balance, negative when it reduces it
+	TransactionRefund          TransactionType = "refund"           // Credit paid back to the borrower
+)
+
+// ParseTransactionType validates the type of an entry added by hand: a fee, an adjustment or a refund.
+func ParseTransactionType(name string) (TransactionType, error) {
+	switch transactionType := TransactionType(name); transactionType {
+	case TransactionFee, TransactionAdjustment, TransactionRefund:
+		return transactionType, nil
+	}
+	return "", fmt.Errorf("unknown transaction type %q, use fee, adjustment or refund", name)
+}
+
+// disbursementID is the ID of the entry that opens the ledger, there is one per loan.
+const disbursementID = "disbursement"
+
+// Structure for each entry of the loan ledger
+type Transaction struct {
+	ID          string          `json:"id"` // Unique identifier of the entry
+	Type        TransactionType `json:"type"`
+	DateTime    string          `json:"date_time"`
+	Description string          `json:"description"`
+	Amount      Money           `json:"amount"`                // Positive, except for the adjustments that reduce the balance
+	Installment int             `json:"installment,omitempty"` // Installment a charge is posted for
+}
+
+// IsCharge reports whether the entry is a charge posted for a late installment.
+func (t Transaction) IsCharge() bool {
+	return t.Type == TransactionLateFee || t.Type == TransactionPenaltyInterest
+}
+
+// IsAutomatic reports whether the entry is posted by loanMgr, so it cannot be changed or
+// removed by hand: the disbursement follows the loan and the charges its payments.
+func (t Transaction) IsAutomatic() bool {
+	switch t.Type {
+	case TransactionPayment, TransactionFee, TransactionAdjustment, TransactionRefund:
+		return false
+	}
+	return true
+}
+
+// validateAmount checks the amount of an entry added by hand: only adjustments can be
+// negative, and none can be zero.
+func (t Transaction) validateAmount() error {
+	if t.Amount == 0 || (t.Amount < 0 && t.Type != TransactionAdjustment) {
+		return fmt.Errorf("%w: the %s must be greater than zero, got %s", ErrInvalidAmount, t.Type, t.Amount)
+	}
+	return nil
+}
+
+// newDisbursement returns the entry that opens the ledger of a loan with the amount lent.
+func newDisbursement(amount Money, dateTime string) Transaction {
+	return Transaction{
+		ID:          disbursementID,
+		Type:        TransactionDisbursement,
+		DateTime:    dateTime,
+		Description: "Loan disbursed",
+		Amount:      amount,
+	}
+}
+
+// Structure for an entry of the ledger replayed, with its effect on the balance
+type LedgerLine struct {
+	Transaction
+	Interest  Money `json:"interest"`  // Part of a payment that paid the interest accrued before it
+	Principal Money `json:"principal"` // Part of a payment that reduced the balance, negative when it did not cover the interest
+	Balance   Money `json:"balance"`   // Balance after the entry, negative when the loan is overpaid
+}
+
+// Structure for the state of a loan derived by replaying its ledger
+type Statement struct {
+	Lines        []LedgerLine `json:"lines"`
+	Balance      Money        `json:"balance"`       // Amount still owed, zero when the loan is overpaid
+	Credit       Money        `json:"credit"`        // Paid above what the loan owed, to be refunded
+	TotalPaid    Money        `json:"total_paid"`    // Sum of the payments
+	InterestPaid Money        `json:"interest_paid"` // Part of the payments that went to interest
+}
+
+// Replay walks the ledger in chronological order and derives the balance of the loan. Before
+// every payment the interest accrued since the previous one is posted, and the payment pays it
+// first. The other entries move the balance by their amount, and the interest accrued before
+// them waits for the next payment. The rate changes are listed on the date they apply from.
+func (l *Loan) Replay() Statement {
+	entries := slices.Clone(l.Ledger)
+	for _, change := range l.RateChanges {
+		entries = append(entries, Transaction{
+			Type:        TransactionRateChange,
+			DateTime:    change.EffectiveDate,
+			Description: fmt.Sprintf("Interest rate changed to %.2f%%", change.Rate),
+		})
+	}
+	sortByDate(entries)
+
+	var statement Statement
+	var balance, pendingInterest Money
+	var lastDate time.Time
+	for _, entry := range entries {
+		date := parseDateTime(entry.DateTime)
+
+		switch entry.Type {
+		case TransactionRateChange:
+			// Informative, the interest accrued already uses the rate of each date
+
+		case TransactionDisbursement:
+			balance += entry.Amount
+			if !date.IsZero() {
+				lastDate = date
+			}
+
+		case TransactionPayment:
+			interest := pendingInterest + l.accruedInterest(balance, lastDate, date)
+			pendingInterest = 0
+			if interest != 0 {
+				balance += interest
+				statement.Lines = append(statement.Lines, LedgerLine{
+					Transaction: Transaction{
+						Type:        TransactionInterest,
+						DateTime:    entry.DateTime,
+						Description: "Interest accrued until the payment",
+						Amount:      interest,
+					},
+					Balance: balance,
+				})
+			}
+
+			// A payment smaller than the accrued interest leaves a negative principal,
+			// so the unpaid interest stays in the balance.
+			balance -= entry.Amount
+			statement.TotalPaid += entry.Amount
+			statement.InterestPaid += interest
+			statement.Lines = append(statement.Lines, LedgerLine{
+				Transaction: entry,
+				Interest:    interest,
+				Principal:   entry.Amount - interest,
+				Balance:     balance,
+			})
+			// An entry dated before the previous one never moves the interest back
+			if date.After(lastDate) {
+				lastDate = date
+			}
+			continue
+
+		default:
+			// Charges, fees, adjustments and refunds
+			if !lastDate.IsZero() && date.After(lastDate) {
+				pendingInterest += l.accruedInterest(balance, lastDate, date)
+				lastDate = date
+			}
+			balance += entry.Amount
+		}
+
+		statement.Lines = append(statement.Lines, LedgerLine{Transaction: entry, Balance: balance})
+	}
+
+	// Whatever was paid above the balance is owed back to the borrower
+	statement.Balance = max(balance, 0)
+	statement.Credit = max(-balance, 0)
+	return statement
+}
+
+// TotalPaid returns the sum of the payments made on the loan.
+func (l *Loan) TotalPaid() Money {
+	return l.Replay().TotalPaid
+}
+
+// InterestPaid returns the part of the payments that went to interest.
+func (l *Loan) InterestPaid() Money {
+	return l.Replay().InterestPaid
+}
+
+// Credit returns what was paid above what the loan owed, to be refunded.
+func (l *Loan) Credit() Money {
+	return l.Replay().Credit
+}
+
+// hasPayments reports whether the borrower made any payment on the loan.
+func (l *Loan) hasPayments() bool {
+	return slices.ContainsFunc(l.Ledger, func(t Transaction) bool { return t.Type == TransactionPayment })
+}
+
+// setLedger replaces the ledger of the loan and updates the payoff time and the status from the
+// new balance. Unless allowCredit is set, when the new ledger pays more than the loan owes (beyond
+// the credit it already had and the rounding tolerance) the ledger is left as it was and
+// ErrOverpayment is returned.
+func (l *Loan) setLedger(ledger []Transaction, allowCredit bool) error {
+	creditBefore := l.Credit()
+	previous := l.Ledger
+
+	l.Ledger = ledger
+	if credit := l.Credit(); !allowCredit && credit > max(creditBefore, PayoffTolerance) {
+		l.Ledger = previous
+		return fmt.Errorf("%w: loan %s would be overpaid by %s", ErrOverpayment, l.LoanID, credit-creditBefore)
+	}
+
+	sortByDate(l.Ledger)
+	l.recalculatePayOff()
+	l.updateStatus()
+	return nil
+}
+
+// AddTransaction adds a fee, an adjustment or a refund to the ledger of the loan. A refund
+// cannot be greater than the credit of the loan.
+func (l *Loan) AddTransaction(transaction Transaction) (Transaction, error) {
+	if _, err := ParseTransactionType(string(transaction.Type)); err != nil {
+		return transaction, err
+	}
+	if err := transaction.validateAmount(); err != nil {
+		return transaction, err
+	}
+	if credit := l.Credit(); transaction.Type == TransactionRefund && transaction.Amount > credit {
+		return transaction, fmt.Errorf("%w: the refund of %s is greater than the credit of %s", ErrInvalidAmount, transaction.Amount, credit)
+	}
+
+	if transaction.ID == "" {
+		transaction.ID = newPaymentID()
+	}
+	transaction.Installment = 0
+
+	ledger := append(slices.Clone(l.Ledger), transaction)
+	if err := l.setLedger(ledger, true); err != nil {
+		return transaction, err
+	}
+
+	log.Info().Str("loan_id", l.LoanID).Str("type", string(transaction.Type)).Stringer("amount", transaction.Amount).Msg("Transaction added")
+	return transaction, nil
+}
+
+// sortByDate sorts the entries in chronological order, keeping the order of the entries of
+// the same date. The disbursement opens the ledger even when an entry is dated before it.
+func sortByDate(entries []Transaction) {
+	slices.SortStableFunc(entries, func(a, b Transaction) int {
+		switch {
+		case a.Type == TransactionDisbursement && b.Type != TransactionDisbursement:
+			return -1
+		case b.Type == TransactionDisbursement && a.Type != TransactionDisbursement:
+			return 1
+		}
+		return parseDateTime(a.DateTime).Compare(parseDateTime(b.DateTime))
+	})
+}
+
+// legacyBalanceDescription describes the adjustment that keeps the balance of a migrated loan.
+const legacyBalanceDescription = "Balance carried over from the previous version"
+
+// MigrateLedgers completes the history of the loans stored before the ledger existed: the
+// ledger is opened with the disbursement of the loan amount on the start date and the entries
+// stored without a type are payments. When replaying the migrated ledger does not give the
+// balance stored by the previous version, an adjustment keeps the stored one. It returns how
+// many loans changed.
+func (u *User) MigrateLedgers() int {
+	changed := 0
+	for i := range u.Loans {
+		loan := &u.Loans[i]
+		migrated := false
+
+		for j := range loan.Ledger {
+			if loan.Ledger[j].Type == "" {
+				loan.Ledger[j].Type = TransactionPayment
+				migrated = true
+			}
+		}
+
+		if !slices.ContainsFunc(loan.Ledger, func(t Transaction) bool { return t.Type == TransactionDisbursement }) {
+			loan.Ledger = slices.Insert(loan.Ledger, 0, newDisbursement(loan.Amount, loan.StartDate))
+			loan.keepLegacyBalance()
+			migrated = true
+		}
+		loan.LegacyBalance = nil
+
+		if migrated {
+			loan.updateStatus()
+			changed++
+		}
+	}
+	return changed
+}
+
+// keepLegacyBalance adds an adjustment after the last entry of a migrated ledger when its
+// replay does not give the balance stored by the previous version. The older versions charged
+// the interest differently, for example a full month before every payment of a loan without a
+// start date, and the stored balance is the one the user agreed with.
+func (l *Loan) keepLegacyBalance() {
+	if l.LegacyBalance == nil {
+		return
+	}
+
+	sortByDate(l.Ledger)
+	statement := l.Replay()
+	difference := *l.LegacyBalance - (statement.Balance - statement.Credit)
+	if difference == 0 {
+		return
+	}
+
+	log.Warn().Str("loan_id", l.LoanID).Stringer("stored_balance", *l.LegacyBalance).Stringer("replayed_balance", statement.Balance-statement.Credit).
+		Stringer("adjustment", difference).Msg("The replayed ledger does not match the stored balance, an adjustment keeps the stored one")
+	l.Ledger = append(l.Ledger, Transaction{
+		ID:          newPaymentID(),
+		Type:        TransactionAdjustment,
+		DateTime:    l.Ledger[len(l.Ledger)-1].DateTime,
+		Description: legacyBalanceDescription,
+		Amount:      difference,
+	})
+}
+
+// UnmarshalJSON reads the loans stored before the ledger existed, whose history is a list of
+// payments identified by payment_id, and keeps their stored balance. MigrateLedgers completes them.
+func (l *Loan) UnmarshalJSON(data []byte) error {
+	type storedLoan Loan // Without the methods, so decoding it does not call UnmarshalJSON again
+	var stored struct {
+		storedLoan
+		Payments []struct {
+			Transaction
+			PaymentID string `json:"payment_id"`
+		} `json:"payments"`
+		RemainingAmount *Money `json:"remaining_amount"`
+		Credit          Money  `json:"credit"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	*l = Loan(stored.storedLoan)
+	if stored.RemainingAmount != nil {
+		// The balance goes below zero by the credit, like in the replay
+		balance := *stored.RemainingAmount - stored.Credit
+		l.LegacyBalance = &balance
+	}
+	for _, payment := range stored.Payments {
+		payment.ID = payment.PaymentID
+		l.Ledger = append(l.Ledger, payment.Transaction)
+	}
+	return nil
+}
+
+// MarshalJSON adds the balances derived from the ledger, so the readers of the stored data and
+// the API do not have to replay it.
+func (l Loan) MarshalJSON() ([]byte, error) {
+	type storedLoan Loan // Without the methods, so encoding it does not call MarshalJSON again
+	statement := l.Replay()
+	return json.Marshal(struct {
+		storedLoan
+		RemainingAmount Money `json:"remaining_amount"`
+		TotalPaid       Money `json:"total_paid"`
+		InterestPaid    Money `json:"interest_paid"`
+		Credit          Money `json:"credit"`
+	}{storedLoan(l), statement.Balance, statement.TotalPaid, statement.InterestPaid, statement.Credit})
+}
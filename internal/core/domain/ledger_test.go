@@ -0,0 +1,316 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// newTestLoan creates a loan granted on the given date, without a term.
+func newTestLoan(t *testing.T, amount string, rate float64, startDate string) Loan {
+	t.Helper()
+	loan := NewLoan("1", "test", mustMoney(t, amount), rate, mustMoney(t, "500"))
+	if err := loan.SetCalendar(startDate, 0, 0); err != nil {
+		t.Fatalf("SetCalendar: %v", err)
+	}
+	return loan
+}
+
+// entry returns a ledger entry dated on the given day.
+func entry(t *testing.T, transactionType TransactionType, date, amount string) Transaction {
+	t.Helper()
+	return Transaction{ID: newPaymentID(), Type: transactionType, DateTime: date, Amount: mustMoney(t, amount)}
+}
+
+func TestReplayOrder(t *testing.T) {
+	loan := newTestLoan(t, "1000", 0, "2026-01-01")
+	loan.Ledger = append(loan.Ledger,
+		entry(t, TransactionPayment, "2026-03-01", "100"),
+		entry(t, TransactionFee, "2026-02-01", "50"),
+		entry(t, TransactionAdjustment, "2026-02-15", "-20"),
+	)
+
+	// The entries are replayed by date, whatever the order they were added in
+	statement := loan.Replay()
+	var types []TransactionType
+	var balances []Money
+	for _, line := range statement.Lines {
+		types = append(types, line.Type)
+		balances = append(balances, line.Balance)
+	}
+
+	wantTypes := []TransactionType{TransactionDisbursement, TransactionFee, TransactionAdjustment, TransactionPayment}
+	if !slices.Equal(types, wantTypes) {
+		t.Errorf("replayed entries = %v, want %v", types, wantTypes)
+	}
+	if wantBalances := []Money{100000, 105000, 103000, 93000}; !slices.Equal(balances, wantBalances) {
+		t.Errorf("balances = %v, want %v", balances, wantBalances)
+	}
+}
+
+func TestReplayInterest(t *testing.T) {
+	tests := []struct {
+		name         string
+		ledger       []Transaction
+		wantInterest []string // Interest paid by each payment, in order
+		wantBalance  string
+	}{
+		{
+			// 10000 at 12% for 30 days is 98.63
+			name:         "one payment",
+			ledger:       []Transaction{entry(t, TransactionPayment, "2026-01-31", "500")},
+			wantInterest: []string{"98.63"},
+			wantBalance:  "9598.63",
+		},
+		{
+			// The second payment accrues from the first one, on the balance it left
+			name:         "two payments",
+			ledger:       []Transaction{entry(t, TransactionPayment, "2026-01-31", "500"), entry(t, TransactionPayment, "2026-03-02", "500")},
+			wantInterest: []string{"98.63", "94.67"},
+			wantBalance:  "9193.30",
+		},
+		{
+			// The interest accrued before the fee waits for the payment: 49.32 on 10000 and
+			// 49.56 on 10050
+			name:         "fee between payments",
+			ledger:       []Transaction{entry(t, TransactionFee, "2026-01-16", "50"), entry(t, TransactionPayment, "2026-01-31", "500")},
+			wantInterest: []string{"98.88"},
+			wantBalance:  "9648.88",
+		},
+		{
+			// A payment below the accrued interest leaves the rest of it in the balance
+			name:         "payment below the interest",
+			ledger:       []Transaction{entry(t, TransactionPayment, "2026-01-31", "50")},
+			wantInterest: []string{"98.63"},
+			wantBalance:  "10048.63",
+		},
+		{
+			// The entry dated before the start date of a stored ledger does not move the
+			// interest back, the next payment is charged one month on 9900
+			name:         "backdated entry",
+			ledger:       []Transaction{entry(t, TransactionPayment, "2025-12-01", "100"), entry(t, TransactionPayment, "2026-01-31", "500")},
+			wantInterest: []string{"0", "97.64"},
+			wantBalance:  "9497.64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := newTestLoan(t, "10000", 12, "2026-01-01")
+			loan.Ledger = append(loan.Ledger, tt.ledger...)
+
+			statement := loan.Replay()
+			var interest []Money
+			for _, line := range statement.Lines {
+				if line.Type == TransactionPayment {
+					interest = append(interest, line.Interest)
+				}
+			}
+
+			var want []Money
+			for _, value := range tt.wantInterest {
+				want = append(want, mustMoney(t, value))
+			}
+			if !slices.Equal(interest, want) {
+				t.Errorf("interest paid = %v, want %v", interest, want)
+			}
+			if want := mustMoney(t, tt.wantBalance); statement.Balance != want {
+				t.Errorf("balance = %s, want %s", statement.Balance, want)
+			}
+		})
+	}
+}
+
+func TestPaymentBeforeStart(t *testing.T) {
+	loan := newTestLoan(t, "10000", 12, "2026-06-01")
+
+	if _, err := loan.AddPayment(entry(t, TransactionPayment, "2026-01-01", "500"), OverpaymentReject); !errors.Is(err, ErrPaymentBeforeStart) {
+		t.Errorf("AddPayment before the start date error = %v, want %v", err, ErrPaymentBeforeStart)
+	}
+	if payments := loan.GetPayments(); len(payments) != 0 {
+		t.Errorf("the loan has %d payments, want 0", len(payments))
+	}
+
+	// A payment on the start date is accepted, whatever its time
+	payment, err := loan.AddPayment(entry(t, TransactionPayment, "2026-06-01", "100"), OverpaymentReject)
+	if err != nil {
+		t.Fatalf("AddPayment on the start date: %v", err)
+	}
+	if err := loan.ModifyPayment(payment.ID, payment.Amount, "", "2026-01-01"); !errors.Is(err, ErrPaymentBeforeStart) {
+		t.Errorf("ModifyPayment before the start date error = %v, want %v", err, ErrPaymentBeforeStart)
+	}
+
+	// The next payment is charged one month of interest on 9900
+	payment, err = loan.AddPayment(entry(t, TransactionPayment, "2026-07-01", "500"), OverpaymentReject)
+	if err != nil {
+		t.Fatalf("AddPayment: %v", err)
+	}
+	for _, line := range loan.Replay().Lines {
+		if line.ID == payment.ID && line.Interest != 9764 {
+			t.Errorf("interest of the payment = %s, want 97.64", line.Interest)
+		}
+	}
+}
+
+func TestOverpayment(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       OverpaymentMode
+		amount     string
+		wantErr    error
+		wantAmount string // Amount of the payment stored
+		wantCredit string
+		wantStatus LoanStatus
+	}{
+		{"reject", OverpaymentReject, "1200", ErrOverpayment, "", "0", StatusActive},
+		{"reject within tolerance", OverpaymentReject, "1000.04", nil, "1000.04", "0.04", StatusPaidOff},
+		{"cap", OverpaymentCap, "1200", nil, "1000", "0", StatusPaidOff},
+		{"credit", OverpaymentCredit, "1200", nil, "1200", "200", StatusPaidOff},
+		{"exact payoff", OverpaymentReject, "1000", nil, "1000", "0", StatusPaidOff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := newTestLoan(t, "1000", 0, "2026-01-01")
+
+			payment, err := loan.AddPayment(entry(t, TransactionPayment, "2026-02-01", tt.amount), tt.mode)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddPayment error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				// The ledger is left as it was
+				if len(loan.Ledger) != 1 {
+					t.Errorf("the ledger has %d entries, want only the disbursement", len(loan.Ledger))
+				}
+			} else if want := mustMoney(t, tt.wantAmount); payment.Amount != want {
+				t.Errorf("payment stored = %s, want %s", payment.Amount, want)
+			}
+
+			if want := mustMoney(t, tt.wantCredit); loan.Credit() != want {
+				t.Errorf("credit = %s, want %s", loan.Credit(), want)
+			}
+			if status := loan.GetStatus(); status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMigrateLedgers(t *testing.T) {
+	tests := []struct {
+		name           string
+		loan           string // Loan stored by a version before the ledger, with float amounts
+		wantAdjustment string // Amount of the adjustment that keeps the stored balance, zero when none
+		wantBalance    string
+		wantCredit     string
+	}{
+		{
+			name: "balance replayed",
+			loan: `{"loan_id": "1", "loan_name": "car", "amount": 1000.0, "remaining_amount": 800.0, "interest": 0,
+				"start_date": "2024-01-01T00:00:00Z", "monthly_payment": 100.0, "payments": [
+				{"payment_id": "a", "date_time": "2024-02-01T00:00:00Z", "amount": 100.0},
+				{"payment_id": "b", "date_time": "2024-03-01T00:00:00Z", "amount": 100.0}]}`,
+			wantAdjustment: "0",
+			wantBalance:    "800",
+			wantCredit:     "0",
+		},
+		{
+			// Without a start date the first payment is charged a month of interest, 50.00, and
+			// the second 29 days, 46.46, so the replay gives 9488.02
+			name: "interest charged differently",
+			loan: `{"loan_id": "1", "loan_name": "car", "amount": 10000.0, "remaining_amount": 9500.0, "interest": 6,
+				"start_date": "", "monthly_payment": 304.22, "payments": [
+				{"payment_id": "a", "date_time": "2024-02-01T00:00:00Z", "amount": 304.22},
+				{"payment_id": "b", "date_time": "2024-03-01T00:00:00Z", "amount": 304.22}]}`,
+			wantAdjustment: "11.98",
+			wantBalance:    "9500",
+			wantCredit:     "0",
+		},
+		{
+			name: "credit kept",
+			loan: `{"loan_id": "1", "loan_name": "car", "amount": 1000.0, "remaining_amount": 0.0, "credit": 20.0, "interest": 0,
+				"start_date": "2024-01-01T00:00:00Z", "monthly_payment": 100.0, "payments": [
+				{"payment_id": "a", "date_time": "2024-02-01T00:00:00Z", "amount": 1020.0}]}`,
+			wantAdjustment: "0",
+			wantBalance:    "0",
+			wantCredit:     "20",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user User
+			if err := json.Unmarshal([]byte(`{"user_name": "alice", "version": 1, "loans": [`+tt.loan+`]}`), &user); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if changed := user.MigrateLedgers(); changed != 1 {
+				t.Errorf("MigrateLedgers changed %d loans, want 1", changed)
+			}
+
+			loan := &user.Loans[0]
+			if disbursement := loan.Ledger[0]; disbursement.Type != TransactionDisbursement || disbursement.Amount != loan.Amount {
+				t.Errorf("first entry = %s of %s, want the disbursement of %s", disbursement.Type, disbursement.Amount, loan.Amount)
+			}
+
+			// The entries stored without a type are payments
+			var adjustment Money
+			for _, entry := range loan.Ledger[1:] {
+				switch {
+				case entry.Type == TransactionAdjustment && entry.Description == legacyBalanceDescription:
+					adjustment += entry.Amount
+				case entry.Type != TransactionPayment:
+					t.Errorf("entry %q is a %s, want a payment", entry.ID, entry.Type)
+				}
+			}
+			if want := mustMoney(t, tt.wantAdjustment); adjustment != want {
+				t.Errorf("adjustment = %s, want %s", adjustment, want)
+			}
+			if want := mustMoney(t, tt.wantBalance); loan.OutstandingBalance() != want {
+				t.Errorf("balance = %s, want %s", loan.OutstandingBalance(), want)
+			}
+			if want := mustMoney(t, tt.wantCredit); loan.Credit() != want {
+				t.Errorf("credit = %s, want %s", loan.Credit(), want)
+			}
+
+			// Migrating again changes nothing
+			if changed := user.MigrateLedgers(); changed != 0 {
+				t.Errorf("MigrateLedgers changed %d loans again, want 0", changed)
+			}
+		})
+	}
+}
+
+func TestAssignPaymentIDs(t *testing.T) {
+	var user User
+	stored := `{"user_name": "alice", "version": 1, "loans": [{"loan_id": "1", "loan_name": "car", "amount": 1000.0,
+		"interest": 0, "start_date": "2024-01-01T00:00:00Z", "monthly_payment": 100.0, "payments": [
+		{"payment_id": "a", "date_time": "2024-02-01T00:00:00Z", "amount": 100.0},
+		{"payment_id": "a", "date_time": "2024-03-01T00:00:00Z", "amount": 100.0},
+		{"date_time": "2024-04-01T00:00:00Z", "amount": 100.0}]}]}`
+	if err := json.Unmarshal([]byte(stored), &user); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	user.MigrateLedgers()
+
+	// The duplicate and the missing IDs are renumbered, the first payment keeps its ID
+	if assigned := user.AssignPaymentIDs(); assigned != 2 {
+		t.Errorf("AssignPaymentIDs changed %d entries, want 2", assigned)
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range user.Loans[0].Ledger {
+		if entry.ID == "" || seen[entry.ID] {
+			t.Errorf("entry %s of %s has a missing or repeated ID %q", entry.Type, entry.DateTime, entry.ID)
+		}
+		seen[entry.ID] = true
+	}
+	if !seen["a"] || !seen[disbursementID] {
+		t.Errorf("IDs = %v, want the first payment to keep a and the disbursement its ID", seen)
+	}
+
+	if assigned := user.AssignPaymentIDs(); assigned != 0 {
+		t.Errorf("AssignPaymentIDs changed %d entries again, want 0", assigned)
+	}
+}
@@ -1,56 +1,70 @@
 package domain
 
 import (
-	"math"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
+// DataVersion is the version of the stored user data. Version 2 stores money as
+// decimal strings instead of floats, version 3 stores the loan history as a ledger.
+const DataVersion = 3
+
 // Structure to represent a user with multiple loans
 type User struct {
-	UserName string `json:"user_name"`
-	Loans    []Loan `json:"loans"`
+	UserName   string `json:"user_name"`
+	Version    int    `json:"version"`
+	LastLoanID int    `json:"last_loan_id"` // Last loan ID given, IDs are never reused
+	Loans      []Loan `json:"loans"`
 }
 
 // Structure to represent a loan
 type Loan struct {
-	LoanID          string    `json:"loan_id"`
-	LoanName        string    `json:"loan_name"`
-	Amount          float64   `json:"amount"`           // Initial loan amount
-	RemainingAmount float64   `json:"remaining_amount"` // Remaining amount to be paid
-	TotalPaid       float64   `json:"total_paid"`       // Total amount paid
-	Interest        float64   `json:"interest"`         // Interest rate
-	MonthlyPayment  float64   `json:"monthly_payment"`  // Estimated Monthly payment amount
-	TimePaidOff     float64   `json:"time_paid_off"`    // Time to pay off the loan
-	Payments        []Payment `json:"payments"`         // Payment history
-}
+	LoanID         string        `json:"loan_id"`
+	LoanName       string        `json:"loan_name"`
+	Status         LoanStatus    `json:"status"`                 // Lifecycle state of the loan
+	Amount         Money         `json:"amount"`                 // Initial loan amount
+	Interest       float64       `json:"interest"`               // Interest rate
+	RateChanges    []RateChange  `json:"rate_changes,omitempty"` // Changes of the interest rate, oldest first
+	Fees           []Fee         `json:"fees,omitempty"`         // Fees charged by the lender
+	Penalty        *PenaltyRule  `json:"penalty,omitempty"`      // Charges of a late installment, nil when there are none
+	StartDate      string        `json:"start_date"`             // Date the loan was granted
+	DueDay         int           `json:"due_day,omitempty"`      // Day of the month the installments are due, zero when unknown
+	Term           int           `json:"term,omitempty"`         // Number of monthly installments, zero until paid off
+	MonthlyPayment Money         `json:"monthly_payment"`        // Estimated Monthly payment amount
+	TimePaidOff    float64       `json:"time_paid_off"`          // Monthly installments left to pay off the loan
+	Ledger         []Transaction `json:"ledger"`                 // History of the loan, the balance is derived by replaying it
 
-// Structure for each payment in the history
-type Payment struct {
-	DateTime    string  `json:"date_time"`
-	Description string  `json:"description"`
-	Amount      float64 `json:"amount"`
+	// Balance stored by the versions before the ledger, nil when unknown. Only MigrateLedgers reads it.
+	LegacyBalance *Money `json:"-"`
 }
 
 func NewUser(userName string) User {
 	return User{
 		UserName: userName,
+		Version:  DataVersion,
 		Loans:    []Loan{},
 	}
 }
 
-func NewLoan(loanID, loanName string, amount, interest, monthlyPayment float64) Loan {
-	return Loan{
-		LoanID:          loanID,
-		LoanName:        loanName,
-		Amount:          amount,
-		RemainingAmount: amount,
-		TotalPaid:       0,
-		Interest:        interest,
-		MonthlyPayment:  monthlyPayment,
-		TimePaidOff:     0,
-		Payments:        []Payment{},
+func NewLoan(loanID, loanName string, amount Money, interest float64, monthlyPayment Money) Loan {
+	startDate := time.Now().Format(time.RFC3339)
+	loan := Loan{
+		LoanID:         loanID,
+		LoanName:       loanName,
+		Status:         StatusActive,
+		Amount:         amount,
+		Interest:       interest,
+		StartDate:      startDate,
+		MonthlyPayment: monthlyPayment,
+		TimePaidOff:    0,
+		Ledger:         []Transaction{newDisbursement(amount, startDate)},
 	}
+	loan.recalculatePayOff()
+	return loan
 }
 
 func (u *User) AddLoan(loan Loan) {
@@ -66,6 +80,23 @@ func (u *User) RemoveLoan(loanID string) {
 	}
 }
 
+// Clone returns a copy of the user whose loans can be changed without changing the user.
+func (u *User) Clone() User {
+	clone := *u
+	clone.Loans = make([]Loan, len(u.Loans))
+	for i, loan := range u.Loans {
+		loan.RateChanges = slices.Clone(loan.RateChanges)
+		loan.Fees = slices.Clone(loan.Fees)
+		loan.Ledger = slices.Clone(loan.Ledger)
+		if loan.Penalty != nil {
+			penalty := *loan.Penalty
+			loan.Penalty = &penalty
+		}
+		clone.Loans[i] = loan
+	}
+	return clone
+}
+
 func (u *User) GetLoans() []Loan {
 	return u.Loans
 }
@@ -78,93 +109,215 @@ func (u *User) GetLoan(loanID string) *Loan {
 	return nil
 }
 
-func (l *Loan) AddPayment(payment Payment) {
-	l.Payments = append(l.Payments, payment)
-	l.RemainingAmount -= payment.Amount
-	l.TotalPaid += payment.Amount
+// AddPayment adds a payment to the loan ledger. The payment is rejected when it is not
+// positive or it is dated before the start date of the loan. A payment greater than what is left to pay is handled as the overpayment mode says.
+func (l *Loan) AddPayment(payment Transaction, mode OverpaymentMode) (Transaction, error) {
+	if payment.Amount <= 0 {
+		return payment, fmt.Errorf("%w: the payment must be greater than zero, got %s", ErrInvalidAmount, payment.Amount)
+	}
+	if err := l.validatePaymentDate(payment.DateTime); err != nil {
+		return payment, err
+	}
+
+	if l.GetStatus() == StatusPaidOff || l.OutstandingBalance() <= 0 {
+		return payment, fmt.Errorf("%w: loan %s", ErrLoanFullyPaid, l.LoanID)
+	}
+
+	if payment.ID == "" {
+		payment.ID = newPaymentID()
+	}
+	payment.Type, payment.Installment = TransactionPayment, 0
+
+	if mode == OverpaymentCap {
+		if payoff := l.PayoffAmount(payment.DateTime); payment.Amount > payoff {
+			log.Info().Str("loan_id", l.LoanID).Stringer("amount", payment.Amount).Stringer("payoff", payoff).Msg("Payment capped at the payoff amount")
+			payment.Amount = payoff
+		}
+	}
+
+	ledger := append(slices.Clone(l.Ledger), payment)
+	if err := l.setLedger(ledger, mode == OverpaymentCredit); err != nil {
+		if errors.Is(err, ErrOverpayment) {
+			return payment, fmt.Errorf("%w, the payoff amount is %s", err, l.PayoffAmount(payment.DateTime))
+		}
+		return payment, err
+	}
 
-	l.recalculatePayOff()
-	log.Info().Str("loan_id", l.LoanID).Float64("amount", l.Amount).Msg("Payment added")
+	l.warnUnpaidInterest(payment.ID)
+	log.Info().Str("loan_id", l.LoanID).Stringer("amount", payment.Amount).Msg("Payment added")
+	return *l.GetPayment(payment.ID), nil
 }
 
-func (l *Loan) GetPayments() []Payment {
-	return l.Payments
+// AssignPaymentIDs gives an ID to every ledger entry stored without one, or sharing it with
+// another entry of the same loan, and returns how many entries were changed.
+func (u *User) AssignPaymentIDs() int {
+	assigned := 0
+	for i := range u.Loans {
+		seen := make(map[string]bool)
+		for j := range u.Loans[i].Ledger {
+			entry := &u.Loans[i].Ledger[j]
+			if entry.ID == "" || seen[entry.ID] {
+				entry.ID = newPaymentID()
+				assigned++
+			}
+			seen[entry.ID] = true
+		}
+	}
+	return assigned
 }
 
-func (l *Loan) GetPayment(paymentDate string) *Payment {
-	for i, payment := range l.Payments {
-		if payment.DateTime == paymentDate {
-			return &l.Payments[i]
+// GetPayments returns the payments made on the loan, without the other entries of the ledger.
+func (l *Loan) GetPayments() []Transaction {
+	var payments []Transaction
+	for _, entry := range l.Ledger {
+		if entry.Type == TransactionPayment {
+			payments = append(payments, entry)
+		}
+	}
+	return payments
+}
+
+// GetPayment returns the entry of the ledger with the given ID, or nil when there is none.
+func (l *Loan) GetPayment(paymentID string) *Transaction {
+	for i, entry := range l.Ledger {
+		if entry.ID == paymentID {
+			return &l.Ledger[i]
 		}
 	}
 	return nil
 }
 
-func (l *Loan) RemovePayment(paymentDate string) {
-	for i, payment := range l.Payments {
-		if payment.DateTime == paymentDate {
-			paymentIndex := i
-			payment := l.Payments[paymentIndex]
+// RemovePayment removes a payment, fee, adjustment or refund from the loan ledger. The
+// disbursement and the charges of late installments cannot be removed, they follow the loan.
+func (l *Loan) RemovePayment(paymentID string) error {
+	for i, entry := range l.Ledger {
+		if entry.ID == paymentID {
+			if entry.IsAutomatic() {
+				return fmt.Errorf("%w: %q is a %s", ErrAutomaticEntry, paymentID, entry.Type)
+			}
 
-			l.RemainingAmount += payment.Amount
-			l.TotalPaid -= payment.Amount
-			l.Payments = append(l.Payments[:paymentIndex], l.Payments[paymentIndex+1:]...)
-			l.recalculatePayOff()
+			// Removing an entry never pays more than the loan owes
+			ledger := slices.Delete(slices.Clone(l.Ledger), i, i+1)
+			if err := l.setLedger(ledger, true); err != nil {
+				return err
+			}
 
-			log.Info().Str("loan_id", l.LoanID).Int("payment_index", paymentIndex).Msg("Payment removed")
-			return
+			log.Info().Str("loan_id", l.LoanID).Str("payment_id", paymentID).Str("type", string(entry.Type)).Msg("Payment removed")
+			return nil
 		}
 	}
+	return fmt.Errorf("%w: %q in loan %s", ErrPaymentNotFound, paymentID, l.LoanID)
 }
 
-func (l *Loan) ModifyPayment(paymentDate string, newAmount float64, newDescription string) {
-	for i, payment := range l.Payments {
-		if payment.DateTime == paymentDate {
-			paymentIndex := i
+// ModifyPayment changes the amount, description and date of a payment, fee, adjustment or
+// refund of the loan ledger. An empty date keeps the current one. The disbursement and the
+// charges of late installments cannot be modified, they follow the loan and its payments.
+func (l *Loan) ModifyPayment(paymentID string, newAmount Money, newDescription string, newDateTime string) error {
+	for i, entry := range l.Ledger {
+		if entry.ID == paymentID {
+			if entry.IsAutomatic() {
+				return fmt.Errorf("%w: %q is a %s", ErrAutomaticEntry, paymentID, entry.Type)
+			}
+
+			// Update the amount, description and date
+			entry.Amount = newAmount
+			entry.Description = newDescription
+			if newDateTime != "" {
+				entry.DateTime = newDateTime
+			}
+			if err := entry.validateAmount(); err != nil {
+				return err
+			}
+			if entry.Type == TransactionPayment {
+				if err := l.validatePaymentDate(entry.DateTime); err != nil {
+					return err
+				}
+			}
 
-			// As we are modifying the payment, we need to UPDATE
-			payment := &l.Payments[paymentIndex]
+			ledger := slices.Clone(l.Ledger)
+			ledger[i] = entry
+			if err := l.setLedger(ledger, entry.Type != TransactionPayment); err != nil {
+				return err
+			}
 
-			// Remove the old amount from the total paid and remaining amount
-			l.RemainingAmount += payment.Amount
-			l.TotalPaid -= payment.Amount
+			l.warnUnpaidInterest(paymentID)
+			log.Info().Str("loan_id", l.LoanID).Str("payment_id", paymentID).Stringer("new_amount", newAmount).Msg("Payment modified")
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q in loan %s", ErrPaymentNotFound, paymentID, l.LoanID)
+}
 
-			// Update the payment amount and description
-			payment.Amount = newAmount
-			payment.Description = newDescription
+// validatePaymentDate rejects a payment dated before the day the loan was granted, since the
+// interest accrues from the start date. Payments of loans without a start date are not checked.
+func (l *Loan) validatePaymentDate(dateTime string) error {
+	start, paid := l.StartTime(), parseDateTime(dateTime)
+	if start.IsZero() || paid.IsZero() {
+		return nil
+	}
 
-			// Update the total paid and remaining amount
-			l.RemainingAmount -= newAmount
-			l.TotalPaid += newAmount
+	if startDay := monthDay(start, 0, start.Day()); paid.Before(startDay) {
+		return fmt.Errorf("%w: the payment of %s is before the start date %s", ErrPaymentBeforeStart,
+			paid.Format(time.DateOnly), start.Format(time.DateOnly))
+	}
+	return nil
+}
 
-			l.recalculatePayOff()
+// MonthlyInterest returns one month of interest on the balance at the annual rate in percent.
+func MonthlyInterest(balance Money, annualRate float64) Money {
+	return Round(balance.Float64()*annualRate/12/100, interestRounding)
+}
 
-			log.Info().Str("loan_id", l.LoanID).Int("payment_index", paymentIndex).Float64("new_amount", newAmount).Msg("Payment modified")
-			return
+// parseDateTime parses the dates stored in the loan data. It returns the zero time when
+// the value is empty or cannot be parsed.
+func parseDateTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
 		}
 	}
-	log.Warn().Str("loan_id", l.LoanID).Str("payment_date", paymentDate).Msg("Payment not found")
+	return time.Time{}
 }
 
-func (l *Loan) recalculatePayOff() {
-	if l.Interest == 0 {
-
-		months := l.RemainingAmount / l.MonthlyPayment
-		l.TimePaidOff = float64(months + 0.9999) // We round up
-		return
+// ParsePaymentDate converts a date given by the user (YYYY-MM-DD or RFC3339) to the RFC3339
+// format stored in the payments. An empty value is the current time.
+func ParsePaymentDate(value string) (string, error) {
+	if value == "" {
+		return time.Now().Format(time.RFC3339), nil
 	}
 
-	// Convert the annual interest rate to a monthly interest rate
-	monthlyInterestRate := l.Interest / 12 / 100
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(time.RFC3339), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q, use YYYY-MM-DD or RFC3339", value)
+}
 
-	//Verify if the monthly payment is enough to cover the interest
-	if l.MonthlyPayment <= l.RemainingAmount*monthlyInterestRate {
-		log.Error().Msg("The monthly payment is too low to cover the interest.")
-		return // Insufficient monthly payment
+// RecalculatePayoffTimes updates the installments left of the loans stored by older versions,
+// which computed them with a wrong formula. It returns how many loans changed.
+func (u *User) RecalculatePayoffTimes() int {
+	changed := 0
+	for i := range u.Loans {
+		loan := &u.Loans[i]
+		before := loan.TimePaidOff
+		loan.recalculatePayOff()
+		if loan.TimePaidOff != before {
+			changed++
+		}
 	}
+	return changed
+}
 
-	numerator := math.Log(l.MonthlyPayment / (l.MonthlyPayment - l.RemainingAmount*l.Interest))
-	denominator := math.Log(1 + l.Interest)
+// recalculatePayOff updates the number of monthly installments left to pay off the loan
+// with the rate and monthly payment in effect today.
+func (l *Loan) recalculatePayOff() {
+	installments, err := InstallmentsForPayment(l.OutstandingBalance(), l.CurrentRate(), l.CurrentMonthlyPayment())
+	if err != nil {
+		log.Error().Err(err).Str("loan_id", l.LoanID).Msg("Could not calculate the time to pay off the loan.")
+		l.TimePaidOff = 0
+		return
+	}
 
-	l.TimePaidOff = numerator / denominator
+	l.TimePaidOff = float64(installments)
 }
@@ -0,0 +1,193 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+)
+
+// Closed-form formulas of a loan paid in equal monthly installments (an annuity). The
+// rates are annual percentages, like Loan.Interest, and are compounded monthly.
+
+// maxRateIterations bounds the bisection of RateForPayment, enough to halve the search
+// interval below any rate that can be told apart in cents.
+const maxRateIterations = 200
+
+// maxAnnualRate is the highest annual rate in percent searched by RateForPayment.
+const maxAnnualRate = 1200
+
+// monthlyRate converts an annual rate in percent to the rate of one month.
+func monthlyRate(annualRate float64) float64 {
+	return annualRate / 12 / 100
+}
+
+// annuityPayment returns the installment that pays off the principal in the given months.
+func annuityPayment(principal, rate float64, months int) float64 {
+	if rate == 0 {
+		return principal / float64(months)
+	}
+	return principal * rate / (1 - math.Pow(1+rate, -float64(months)))
+}
+
+// PaymentForTerm returns the monthly payment that pays off the principal in the given number
+// of months at the annual rate. It is rounded up to the cent, so the last installment may be
+// a few cents smaller.
+func PaymentForTerm(principal Money, annualRate float64, months int) (Money, error) {
+	switch {
+	case months <= 0:
+		return 0, fmt.Errorf("%w: the term must be at least one month, got %d", ErrInvalidLoan, months)
+	case annualRate < 0 || math.IsNaN(annualRate) || math.IsInf(annualRate, 0):
+		return 0, fmt.Errorf("%w: the interest rate must be zero or positive, got %v", ErrInvalidRate, annualRate)
+	case principal <= 0:
+		return 0, nil
+	}
+
+	return Round(annuityPayment(principal.Float64(), monthlyRate(annualRate), months), RoundUp), nil
+}
+
+// TermForPayment returns the number of months, with decimals, the monthly payment takes to pay
+// off the principal at the annual rate. The last installment is the fraction of a payment.
+// It returns ErrPaymentTooLow when the payment does not cover the interest of a month.
+func TermForPayment(principal Money, annualRate float64, payment Money) (float64, error) {
+	if principal <= 0 {
+		return 0, nil
+	}
+
+	rate := monthlyRate(annualRate)
+	if interest := principal.Float64() * rate; payment.Float64() <= interest {
+		return 0, fmt.Errorf("%w: a monthly payment of %s does not cover the %.2f of interest of a month",
+			ErrPaymentTooLow, payment, interest)
+	}
+
+	if rate == 0 {
+		return principal.Float64() / payment.Float64(), nil
+	}
+	return -math.Log(1-rate*principal.Float64()/payment.Float64()) / math.Log(1+rate), nil
+}
+
+// InstallmentsForPayment returns the number of monthly installments, the last one maybe
+// smaller, the payment takes to pay off the principal at the annual rate.
+func InstallmentsForPayment(principal Money, annualRate float64, payment Money) (int, error) {
+	months, err := TermForPayment(principal, annualRate, payment)
+	if err != nil {
+		return 0, err
+	}
+
+	// Ignore the float noise of a term that is a whole number of months
+	return int(math.Ceil(months - 1e-9)), nil
+}
+
+// BalanceAfter returns the balance left after paying the monthly payment for the given number of
+// months at the annual rate. It is never negative.
+func BalanceAfter(principal Money, annualRate float64, payment Money, months int) Money {
+	balance := principal.Float64()
+	rate := monthlyRate(annualRate)
+	n := float64(max(months, 0))
+
+	if rate == 0 {
+		balance -= payment.Float64() * n
+	} else {
+		growth := math.Pow(1+rate, n)
+		balance = balance*growth - payment.Float64()*(growth-1)/rate
+	}
+
+	return max(Round(balance, interestRounding), 0)
+}
+
+// TotalInterest returns the interest paid over the life of a loan paid with the monthly payment
+// at the annual rate, with the last installment paying only what is left.
+func TotalInterest(principal Money, annualRate float64, payment Money) (Money, error) {
+	installments, err := InstallmentsForPayment(principal, annualRate, payment)
+	if err != nil || installments == 0 {
+		return 0, err
+	}
+
+	// Every installment but the last is a full payment
+	left := BalanceAfter(principal, annualRate, payment, installments-1)
+	last := Round(left.Float64()*(1+monthlyRate(annualRate)), interestRounding)
+	total := payment*Money(installments-1) + last
+
+	return total - principal, nil
+}
+
+// RateForPayment returns the annual rate in percent at which the monthly payment pays off the
+// principal in the given number of months. There is no closed form, so it is found by bisection.
+func RateForPayment(principal Money, payment Money, months int) (float64, error) {
+	switch {
+	case months <= 0:
+		return 0, fmt.Errorf("%w: the term must be at least one month, got %d", ErrInvalidLoan, months)
+	case principal <= 0 || payment <= 0:
+		return 0, fmt.Errorf("%w: the principal and the payment must be greater than zero", ErrInvalidAmount)
+	case payment*Money(months) < principal:
+		return 0, fmt.Errorf("%w: %d payments of %s do not repay %s even without interest", ErrPaymentTooLow, months, payment, principal)
+	case payment*Money(months) == principal:
+		return 0, nil
+	}
+
+	// The payment grows with the rate, so the rate is between the bounds
+	low, high := 0.0, monthlyRate(maxAnnualRate)
+	if annuityPayment(principal.Float64(), high, months) < payment.Float64() {
+		return 0, fmt.Errorf("%w: the rate is above %d%%", ErrInvalidRate, maxAnnualRate)
+	}
+
+	for range maxRateIterations {
+		rate := (low + high) / 2
+		if annuityPayment(principal.Float64(), rate, months) < payment.Float64() {
+			low = rate
+		} else {
+			high = rate
+		}
+	}
+
+	return (low + high) / 2 * 12 * 100, nil
+}
+
+// EffectiveAnnualRate returns the annual rate in percent equivalent to the annual rate compounded
+// monthly, without fees.
+func EffectiveAnnualRate(annualRate float64) float64 {
+	return (math.Pow(1+monthlyRate(annualRate), 12) - 1) * 100
+}
+
+// APR returns the annual percentage rate (TAE) in percent of a loan that pays out the net amount
+// and is repaid with the given monthly installments, fees included. It is the monthly rate that
+// discounts the installments to the net amount, compounded over a year. There is no closed
+// form, so it is found by bisection.
+func APR(net Money, installments []Money) (float64, error) {
+	var total Money
+	for _, installment := range installments {
+		total += installment
+	}
+
+	switch {
+	case net <= 0:
+		return 0, fmt.Errorf("%w: the fees take the whole amount of the loan", ErrInvalidAmount)
+	case total < net:
+		return 0, fmt.Errorf("%w: the installments of %s do not repay the %s received", ErrPaymentTooLow, total, net)
+	case total == net:
+		return 0, nil
+	}
+
+	// The present value of the installments goes down as the rate goes up
+	presentValue := func(rate float64) float64 {
+		var value float64
+		for k, installment := range installments {
+			value += installment.Float64() / math.Pow(1+rate, float64(k+1))
+		}
+		return value
+	}
+
+	low, high := 0.0, monthlyRate(maxAnnualRate)
+	if presentValue(high) > net.Float64() {
+		return 0, fmt.Errorf("%w: the rate is above %d%%", ErrInvalidRate, maxAnnualRate)
+	}
+
+	for range maxRateIterations {
+		rate := (low + high) / 2
+		if presentValue(rate) > net.Float64() {
+			low = rate
+		} else {
+			high = rate
+		}
+	}
+
+	return (math.Pow(1+(low+high)/2, 12) - 1) * 100, nil
+}
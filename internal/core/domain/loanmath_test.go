@@ -0,0 +1,337 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// mustMoney parses an amount written in the tests.
+func mustMoney(t *testing.T, value string) Money {
+	t.Helper()
+	amount, err := ParseMoney(value)
+	if err != nil {
+		t.Fatalf("ParseMoney(%q): %v", value, err)
+	}
+	return amount
+}
+
+func TestPaymentForTerm(t *testing.T) {
+	// Published annuity payments, rounded up to the cent
+	tests := []struct {
+		name      string
+		principal string
+		rate      float64
+		months    int
+		want      string
+	}{
+		{"car loan", "10000", 6, 36, "304.22"},               // 304.2194
+		{"one year", "5000", 12, 12, "444.25"},               // 444.2439
+		{"five years", "20000", 5, 60, "377.43"},             // 377.4247
+		{"mortgage", "200000", 4.5, 360, "1013.38"},          // 1013.3706
+		{"zero rate", "1000", 0, 10, "100.00"},               // Principal split in equal parts
+		{"zero rate with remainder", "1200", 0, 7, "171.43"}, // 171.4286
+		{"nothing to pay", "0", 5, 12, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PaymentForTerm(mustMoney(t, tt.principal), tt.rate, tt.months)
+			if err != nil {
+				t.Fatalf("PaymentForTerm: %v", err)
+			}
+			if want := mustMoney(t, tt.want); got != want {
+				t.Errorf("PaymentForTerm(%s, %v, %d) = %s, want %s", tt.principal, tt.rate, tt.months, got, want)
+			}
+		})
+	}
+}
+
+func TestPaymentForTermInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		months  int
+		wantErr error
+	}{
+		{"zero term", 5, 0, ErrInvalidLoan},
+		{"negative term", 5, -12, ErrInvalidLoan},
+		{"negative rate", -1, 12, ErrInvalidRate},
+		{"NaN rate", math.NaN(), 12, ErrInvalidRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := PaymentForTerm(mustMoney(t, "1000"), tt.rate, tt.months); !errors.Is(err, tt.wantErr) {
+				t.Errorf("PaymentForTerm error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTermForPayment(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal string
+		rate      float64
+		payment   string
+		want      float64
+	}{
+		{"car loan", "10000", 6, "304.22", 35.9998},
+		{"mortgage", "200000", 4.5, "1013.37", 360.0005},
+		{"zero rate", "1000", 0, "100", 10},
+		{"zero rate partial month", "1000", 0, "300", 3.3333},
+		{"nothing to pay", "0", 5, "100", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TermForPayment(mustMoney(t, tt.principal), tt.rate, mustMoney(t, tt.payment))
+			if err != nil {
+				t.Fatalf("TermForPayment: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-3 {
+				t.Errorf("TermForPayment(%s, %v, %s) = %.4f, want %.4f", tt.principal, tt.rate, tt.payment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTermForPaymentRoundTrip(t *testing.T) {
+	tests := []struct {
+		principal string
+		rate      float64
+		months    int
+	}{
+		{"10000", 6, 36},
+		{"5000", 12, 12},
+		{"20000", 5, 60},
+		{"200000", 4.5, 360},
+		{"150000", 3, 300},
+		{"1200", 0, 7},
+		{"3000", 19.9, 42},
+	}
+
+	for _, tt := range tests {
+		principal := mustMoney(t, tt.principal)
+		payment, err := PaymentForTerm(principal, tt.rate, tt.months)
+		if err != nil {
+			t.Fatalf("PaymentForTerm: %v", err)
+		}
+
+		// The payment is rounded up, so the last installment is a few cents smaller
+		installments, err := InstallmentsForPayment(principal, tt.rate, payment)
+		if err != nil {
+			t.Fatalf("InstallmentsForPayment: %v", err)
+		}
+		if installments != tt.months {
+			t.Errorf("InstallmentsForPayment(%s, %v, %s) = %d, want %d", tt.principal, tt.rate, payment, installments, tt.months)
+		}
+	}
+}
+
+func TestPaymentTooLow(t *testing.T) {
+	tests := []struct {
+		name    string
+		payment string
+	}{
+		{"below the interest", "49.99"},
+		{"equal to the interest", "50.00"},
+	}
+
+	// 10000 at 6% accrues 50.00 of interest a month
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := mustMoney(t, tt.payment)
+			if _, err := TermForPayment(mustMoney(t, "10000"), 6, payment); !errors.Is(err, ErrPaymentTooLow) {
+				t.Errorf("TermForPayment error = %v, want %v", err, ErrPaymentTooLow)
+			}
+			if _, err := InstallmentsForPayment(mustMoney(t, "10000"), 6, payment); !errors.Is(err, ErrPaymentTooLow) {
+				t.Errorf("InstallmentsForPayment error = %v, want %v", err, ErrPaymentTooLow)
+			}
+			if _, err := TotalInterest(mustMoney(t, "10000"), 6, payment); !errors.Is(err, ErrPaymentTooLow) {
+				t.Errorf("TotalInterest error = %v, want %v", err, ErrPaymentTooLow)
+			}
+		})
+	}
+
+	if _, err := RateForPayment(mustMoney(t, "1000"), mustMoney(t, "99.99"), 10); !errors.Is(err, ErrPaymentTooLow) {
+		t.Errorf("RateForPayment error = %v, want %v", err, ErrPaymentTooLow)
+	}
+}
+
+func TestRateForPayment(t *testing.T) {
+	tests := []struct {
+		principal string
+		rate      float64
+		months    int
+	}{
+		{"10000", 6, 36},
+		{"5000", 12, 12},
+		{"20000", 5, 60},
+		{"200000", 4.5, 360},
+		{"3000", 19.9, 42},
+	}
+
+	for _, tt := range tests {
+		principal := mustMoney(t, tt.principal)
+		payment, err := PaymentForTerm(principal, tt.rate, tt.months)
+		if err != nil {
+			t.Fatalf("PaymentForTerm: %v", err)
+		}
+
+		// The payment is rounded up to the cent, so the rate found is a little higher
+		got, err := RateForPayment(principal, payment, tt.months)
+		if err != nil {
+			t.Fatalf("RateForPayment: %v", err)
+		}
+		if got < tt.rate-1e-9 || got-tt.rate > 0.01 {
+			t.Errorf("RateForPayment(%s, %s, %d) = %.6f, want %v", tt.principal, payment, tt.months, got, tt.rate)
+		}
+	}
+
+	// Paying back exactly the principal is a zero rate
+	if got, err := RateForPayment(mustMoney(t, "1000"), mustMoney(t, "100"), 10); err != nil || got != 0 {
+		t.Errorf("RateForPayment without interest = %v, %v, want 0", got, err)
+	}
+}
+
+func TestBalanceAfter(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal string
+		rate      float64
+		payment   string
+		months    int
+		want      string
+	}{
+		{"no installments", "10000", 6, "304.22", 0, "10000.00"},
+		{"first installment", "10000", 6, "304.22", 1, "9745.78"},
+		{"one year", "10000", 6, "304.22", 12, "6864.05"},
+		{"zero rate", "1000", 0, "100", 4, "600.00"},
+		{"paid off", "1000", 0, "100", 15, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BalanceAfter(mustMoney(t, tt.principal), tt.rate, mustMoney(t, tt.payment), tt.months)
+			if want := mustMoney(t, tt.want); got != want {
+				t.Errorf("BalanceAfter(%s, %v, %s, %d) = %s, want %s", tt.principal, tt.rate, tt.payment, tt.months, got, want)
+			}
+		})
+	}
+}
+
+func TestBalanceAfterMatchesSchedule(t *testing.T) {
+	tests := []struct {
+		amount  string
+		rate    float64
+		payment string
+	}{
+		{"10000", 6, "304.22"},
+		{"3000", 19.9, "100"},
+		{"6000", 3, "80"},
+		{"1000", 0, "75"},
+	}
+
+	for _, tt := range tests {
+		loan := NewLoan("1", "test", mustMoney(t, tt.amount), tt.rate, mustMoney(t, tt.payment))
+		schedule, err := loan.AmortizationSchedule(time.Now())
+		if err != nil {
+			t.Fatalf("AmortizationSchedule: %v", err)
+		}
+
+		// The schedule rounds the interest of every month and the closed form only the result,
+		// so they drift apart by at most a cent every few months
+		for _, period := range schedule.Periods {
+			got := BalanceAfter(loan.Amount, tt.rate, loan.MonthlyPayment, period.Number)
+			if diff := got - period.ClosingBalance; diff < -Money(period.Number) || diff > Money(period.Number) {
+				t.Errorf("%s at %v%%: BalanceAfter(%d) = %s, schedule has %s", tt.amount, tt.rate, period.Number, got, period.ClosingBalance)
+			}
+		}
+
+		if installments, _ := InstallmentsForPayment(loan.Amount, tt.rate, loan.MonthlyPayment); installments != len(schedule.Periods) {
+			t.Errorf("%s at %v%%: InstallmentsForPayment = %d, schedule has %d installments", tt.amount, tt.rate, installments, len(schedule.Periods))
+		}
+	}
+}
+
+func TestTotalInterest(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal string
+		rate      float64
+		payment   string
+		want      string
+	}{
+		{"car loan", "10000", 6, "304.22", "951.89"},
+		{"zero rate", "1000", 0, "75", "0"},
+		{"nothing to pay", "0", 6, "100", "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TotalInterest(mustMoney(t, tt.principal), tt.rate, mustMoney(t, tt.payment))
+			if err != nil {
+				t.Fatalf("TotalInterest: %v", err)
+			}
+			if want := mustMoney(t, tt.want); got != want {
+				t.Errorf("TotalInterest(%s, %v, %s) = %s, want %s", tt.principal, tt.rate, tt.payment, got, want)
+			}
+		})
+	}
+}
+
+func TestEffectiveAnnualRate(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want float64
+	}{
+		{0, 0},
+		{6, 6.1678},
+		{12, 12.6825},
+		{19.9, 21.8192},
+	}
+
+	for _, tt := range tests {
+		if got := EffectiveAnnualRate(tt.rate); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("EffectiveAnnualRate(%v) = %.4f, want %.4f", tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestAPR(t *testing.T) {
+	tests := []struct {
+		name         string
+		net          string
+		installments []Money
+		want         float64
+	}{
+		// Without fees the APR is the effective rate of the nominal one
+		{"no fees", "10000", slices.Repeat([]Money{30422}, 36), 6.1679},
+		// An opening fee of 300 is not received, so the same installments cost more
+		{"opening fee", "9700", slices.Repeat([]Money{30422}, 36), 8.3618},
+		{"no interest", "1000", slices.Repeat([]Money{10000}, 10), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := APR(mustMoney(t, tt.net), tt.installments)
+			if err != nil {
+				t.Fatalf("APR: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-3 {
+				t.Errorf("APR = %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := APR(mustMoney(t, "1000"), slices.Repeat([]Money{9000}, 10)); !errors.Is(err, ErrPaymentTooLow) {
+		t.Errorf("APR error = %v, want %v", err, ErrPaymentTooLow)
+	}
+	if _, err := APR(0, []Money{100}); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("APR error = %v, want %v", err, ErrInvalidAmount)
+	}
+}
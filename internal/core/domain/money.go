@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact monetary amount stored in minor units (cents), so adding and
+// subtracting payments never drifts the way float64 balances do.
+type Money int64
+
+// RoundingMode decides how amounts with more than two decimals are rounded to cents.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds ties away from zero (2.345 -> 2.35). Used for amounts typed by the user.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds ties to the even cent (2.345 -> 2.34), also known as banker's rounding.
+	RoundHalfEven
+	// RoundUp rounds up to the next cent (2.341 -> 2.35). Used for installments, so they never fall short.
+	RoundUp
+)
+
+// interestRounding is the rounding applied to interest accrued on a balance.
+const interestRounding = RoundHalfEven
+
+const centsPerUnit = 100
+
+// Round converts an amount expressed in currency units to Money using the given rounding mode.
+func Round(amount float64, mode RoundingMode) Money {
+	// Strip the binary noise of the float (e.g. 28.4999999999 instead of 28.5)
+	// before deciding which way a tie goes.
+	scaled := math.Round(amount*centsPerUnit*1e6) / 1e6
+
+	switch mode {
+	case RoundHalfEven:
+		return Money(math.RoundToEven(scaled))
+	case RoundUp:
+		return Money(math.Ceil(scaled))
+	}
+	return Money(math.Round(scaled))
+}
+
+// MoneyFromFloat converts an amount expressed in currency units to Money, rounding half-up.
+func MoneyFromFloat(amount float64) Money {
+	m, err := ParseMoney(strconv.FormatFloat(amount, 'f', -1, 64))
+	if err != nil {
+		return Round(amount, RoundHalfUp)
+	}
+	return m
+}
+
+// ParseMoney parses a decimal amount such as "1234.56", "-3" or "12,5". Extra decimals
+// are rounded half-up without going through a float.
+func ParseMoney(value string) (Money, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	// Exponent notation can only come from a JSON number, let the float parser handle it
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %q", value)
+		}
+		return Round(f, RoundHalfUp), nil
+	}
+
+	// Accept a comma as decimal separator
+	if !strings.Contains(s, ".") {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || (frac != "" && !isDigits(frac)) {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/centsPerUnit-1 {
+		return 0, fmt.Errorf("amount %q out of range", value)
+	}
+
+	// Keep two decimals and round with the third one
+	frac += "000"
+	cents, _ := strconv.ParseInt(frac[:2], 10, 64)
+	m := Money(units*centsPerUnit + cents)
+	if frac[2] >= '5' {
+		m++
+	}
+
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Float64 returns the amount in currency units, for calculations that need a rate.
+func (m Money) Float64() float64 {
+	return float64(m) / centsPerUnit
+}
+
+// Abs returns the absolute value of the amount.
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// String formats the amount with two decimals, e.g. "1234.56".
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+	}
+	abs := m.Abs()
+	return fmt.Sprintf("%s%d.%02d", sign, abs/centsPerUnit, abs%centsPerUnit)
+}
+
+// MarshalJSON stores the amount as a decimal string so it is never read back as a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts both the decimal string format and the plain numbers written
+// by older versions of loanMgr.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(raw, `"`) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		raw = s
+	}
+
+	parsed, err := ParseMoney(raw)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Money
+	}{
+		{"10", 1000},
+		{"10.5", 1050},
+		{"10.05", 1005},
+		{"10.004", 1000},
+		{"10.005", 1001}, // The third decimal rounds half-up
+		{"10.009", 1001},
+		{"0.995", 100},
+		{"-3", -300},
+		{"-10.005", -1001}, // Half-up rounds away from zero
+		{"+7", 700},
+		{".5", 50},
+		{"12,5", 1250}, // Comma as decimal separator
+		{" 3 ", 300},
+		{"1e2", 10000}, // Exponent written by a JSON encoder
+		{"0", 0},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value)
+		if err != nil {
+			t.Errorf("ParseMoney(%q): %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, value := range []string{"", " ", "abc", "-", ".", "1.2.3", "1,2,3", "12a", "--1", "1 000", "e5", "99999999999999999999"} {
+		if got, err := ParseMoney(value); err == nil {
+			t.Errorf("ParseMoney(%q) = %s, want an error", value, got)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		halfUp   Money
+		halfEven Money
+		up       Money
+	}{
+		{2.345, 235, 234, 235},
+		{2.355, 236, 236, 236},
+		{2.341, 234, 234, 235},
+		{2.34, 234, 234, 234}, // 233.99999999999997 cents as a float
+		{0.005, 1, 0, 1},
+		{0.015, 2, 2, 2},
+		{28.495, 2850, 2850, 2850},
+		{-2.345, -235, -234, -234},
+		{0, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		for mode, want := range map[RoundingMode]Money{RoundHalfUp: tt.halfUp, RoundHalfEven: tt.halfEven, RoundUp: tt.up} {
+			if got := Round(tt.amount, mode); got != want {
+				t.Errorf("Round(%v, %d) = %d, want %d", tt.amount, mode, got, want)
+			}
+		}
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   Money
+	}{
+		{0.1 + 0.2, 30}, // 0.30000000000000004
+		{304.22, 30422},
+		{1.005, 101},
+		{-0.5, -50},
+	}
+
+	for _, tt := range tests {
+		if got := MoneyFromFloat(tt.amount); got != tt.want {
+			t.Errorf("MoneyFromFloat(%v) = %d, want %d", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount Money
+		want   string
+	}{
+		{123456, "1234.56"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{-123400, "-1234.00"},
+		{0, "0.00"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want Money
+	}{
+		{`"12.30"`, 1230},
+		{`12.3`, 1230}, // Number written by the older versions
+		{`"-0.05"`, -5},
+		{`1013.375`, 101338},
+		{`null`, 4200}, // Keeps the current value
+	}
+
+	for _, tt := range tests {
+		amount := Money(4200)
+		if err := json.Unmarshal([]byte(tt.data), &amount); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.data, err)
+			continue
+		}
+		if amount != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.data, amount, tt.want)
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`"ten"`), new(Money)); err == nil {
+		t.Error(`Unmarshal("ten") succeeded, want an error`)
+	}
+
+	data, err := json.Marshal(Money(101338))
+	if err != nil || string(data) != `"1013.38"` {
+		t.Errorf("Marshal(1013.38) = %s, %v, want \"1013.38\"", data, err)
+	}
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+)
+
+// PayoffTolerance is the balance under which a loan is considered paid off, so the few
+// cents left by the rounding of the interest do not keep a loan open.
+const PayoffTolerance Money = 5
+
+// OverpaymentMode tells what to do with a payment greater than what the loan owes.
+type OverpaymentMode string
+
+const (
+	OverpaymentReject OverpaymentMode = "reject" // Refuse the payment with ErrOverpayment
+	OverpaymentCap    OverpaymentMode = "cap"    // Reduce the payment to the payoff amount
+	OverpaymentCredit OverpaymentMode = "credit" // Keep the payment and record the excess as a credit to refund
+)
+
+// ParseOverpaymentMode validates an overpayment mode name.
+func ParseOverpaymentMode(name string) (OverpaymentMode, error) {
+	switch mode := OverpaymentMode(name); mode {
+	case OverpaymentReject, OverpaymentCap, OverpaymentCredit:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown overpayment mode %q, use reject, cap or credit", name)
+}
+
+// maxPayoffIterations bounds the search of the payoff amount. Each step corrects the
+// amount by the difference left by the previous one, so it converges in a few steps.
+const maxPayoffIterations = 10
+
+// PayoffAmount returns the payment that leaves the loan fully paid when made at the given
+// date (RFC3339 or YYYY-MM-DD), including the interest accrued until then.
+func (l *Loan) PayoffAmount(dateTime string) Money {
+	trial := *l
+	amount := l.OutstandingBalance()
+
+	for range maxPayoffIterations {
+		trial.Ledger = append(slices.Clone(l.Ledger), Transaction{Type: TransactionPayment, Amount: amount, DateTime: dateTime})
+		statement := trial.Replay()
+
+		switch {
+		case statement.Credit > 0:
+			amount -= statement.Credit
+		case statement.Balance > 0:
+			amount += statement.Balance
+		default:
+			return amount
+		}
+	}
+	return amount
+}
+
+// isPaidOff reports whether the balance is zero within the rounding tolerance.
+func (l *Loan) isPaidOff() bool {
+	return l.hasPayments() && l.OutstandingBalance() <= PayoffTolerance
+}
@@ -0,0 +1,230 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Structure for the charges of a loan when an installment is paid late
+type PenaltyRule struct {
+	GraceDays   int     `json:"grace_days"`   // Days after the due date before an installment is late
+	LateFee     Money   `json:"late_fee"`     // Fixed fee charged once for each late installment
+	PenaltyRate float64 `json:"penalty_rate"` // Annual rate in percent charged on the unpaid part of a late installment since its due date
+}
+
+// ValidatePenaltyRule checks the grace days, fee and rate are not negative and that the rule
+// charges something.
+func ValidatePenaltyRule(rule PenaltyRule) error {
+	switch {
+	case rule.GraceDays < 0:
+		return fmt.Errorf("%w: the grace days cannot be negative, got %d", ErrInvalidLoan, rule.GraceDays)
+	case rule.LateFee < 0:
+		return fmt.Errorf("%w: the late fee cannot be negative, got %s", ErrInvalidAmount, rule.LateFee)
+	case math.IsNaN(rule.PenaltyRate) || math.IsInf(rule.PenaltyRate, 0) || rule.PenaltyRate < 0:
+		return fmt.Errorf("%w: the penalty rate must be zero or positive, got %v", ErrInvalidRate, rule.PenaltyRate)
+	case rule.LateFee == 0 && rule.PenaltyRate == 0:
+		return fmt.Errorf("%w: the penalty needs a late fee or a penalty rate", ErrInvalidAmount)
+	}
+	return nil
+}
+
+// SetPenaltyRule sets the charges of a late installment of the loan, nil removes them. The
+// charges are posted by ApplyPenalties.
+func (l *Loan) SetPenaltyRule(rule *PenaltyRule) error {
+	if rule != nil {
+		if err := ValidatePenaltyRule(*rule); err != nil {
+			return err
+		}
+	}
+
+	l.Penalty = rule
+	return nil
+}
+
+// TotalCharges returns the sum of the late fees and penalty interest posted on the loan.
+func (l *Loan) TotalCharges() Money {
+	var total Money
+	for _, entry := range l.Ledger {
+		if entry.IsCharge() {
+			total += entry.Amount
+		}
+	}
+	return total
+}
+
+// ApplyPenalties brings the late fees and penalty interest of the loan up to date with its
+// payments on the given date. Every installment paid, or still unpaid, more than the grace days
+// after its due date is charged the late fee once, and the penalty interest on its unpaid part
+// from the due date until it was paid. Charges that no longer apply, because a late payment was
+// corrected or the rule removed, are reversed. Only open loans with a payment calendar are
+// charged. It returns how many charges were posted and reversed.
+func (l *Loan) ApplyPenalties(date time.Time) (posted, reversed int, err error) {
+	if !l.GetStatus().IsOpen() {
+		return 0, 0, nil
+	}
+
+	var charges []Transaction
+	if l.Penalty != nil {
+		if charges, err = l.penaltyCharges(date); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	type chargeKey struct {
+		Type        TransactionType
+		Installment int
+	}
+	wanted := make(map[chargeKey]Transaction, len(charges))
+	for _, charge := range charges {
+		wanted[chargeKey{charge.Type, charge.Installment}] = charge
+	}
+
+	// Keep the other entries and the charges still wanted, updated, with their IDs
+	changed := false
+	ledger := make([]Transaction, 0, len(l.Ledger)+len(charges))
+	for _, entry := range l.Ledger {
+		if !entry.IsCharge() {
+			ledger = append(ledger, entry)
+			continue
+		}
+
+		key := chargeKey{entry.Type, entry.Installment}
+		charge, ok := wanted[key]
+		if !ok {
+			log.Info().Str("loan_id", l.LoanID).Str("type", string(entry.Type)).Int("installment", entry.Installment).
+				Stringer("amount", entry.Amount).Msg("Charge reversed")
+			reversed++
+			changed = true
+			continue
+		}
+		delete(wanted, key)
+
+		if entry.Amount != charge.Amount || entry.DateTime != charge.DateTime || entry.Description != charge.Description {
+			entry.Amount, entry.DateTime, entry.Description = charge.Amount, charge.DateTime, charge.Description
+			changed = true
+		}
+		ledger = append(ledger, entry)
+	}
+
+	for _, charge := range charges {
+		if _, ok := wanted[chargeKey{charge.Type, charge.Installment}]; !ok {
+			continue
+		}
+		charge.ID = fmt.Sprintf("%s-%d", charge.Type, charge.Installment) // One of each type per installment
+		ledger = append(ledger, charge)
+		log.Info().Str("loan_id", l.LoanID).Str("type", string(charge.Type)).Int("installment", charge.Installment).
+			Stringer("amount", charge.Amount).Msg("Charge posted")
+		posted++
+		changed = true
+	}
+
+	if changed {
+		// The charges are owed whatever the balance, so they never overpay the loan
+		if err := l.setLedger(ledger, true); err != nil {
+			return 0, 0, err
+		}
+	}
+	return posted, reversed, nil
+}
+
+// penaltyCharges returns the late fees and penalty interest the payments made up to the given
+// date owe under the penalty rule. The payments cover the installments in order.
+func (l *Loan) penaltyCharges(date time.Time) ([]Transaction, error) {
+	installments, err := l.Installments()
+	if err != nil || len(installments) == 0 {
+		return nil, err
+	}
+
+	// Running total of the payments made up to the date, by date
+	type paid struct {
+		date  time.Time
+		total Money
+	}
+	var payments []paid
+	for _, entry := range l.Ledger {
+		paymentDate := parseDateTime(entry.DateTime)
+		if entry.Type != TransactionPayment || paymentDate.After(date) {
+			continue
+		}
+		payments = append(payments, paid{date: paymentDate, total: entry.Amount})
+	}
+	slices.SortStableFunc(payments, func(a, b paid) int { return a.date.Compare(b.date) })
+	for i := 1; i < len(payments); i++ {
+		payments[i].total += payments[i-1].total
+	}
+
+	rule := l.Penalty
+
+	var charges []Transaction
+	var expected Money
+	for _, installment := range installments {
+		expected += installment.Amount
+		if !installment.DueDate.Before(date) {
+			break
+		}
+
+		// The installment is paid on the first date the running total reaches it
+		paidOn := date
+		for _, payment := range payments {
+			if payment.total >= expected {
+				paidOn = payment.date
+				break
+			}
+		}
+
+		// Paid any time until the last day of grace is on time
+		lateFrom := installment.DueDate.AddDate(0, 0, rule.GraceDays+1)
+		if paidOn.Before(lateFrom) {
+			continue
+		}
+		dueDate := installment.DueDate.Format(time.DateOnly)
+
+		if rule.LateFee > 0 {
+			charges = append(charges, Transaction{
+				Type:        TransactionLateFee,
+				Installment: installment.Number,
+				DateTime:    lateFrom.Format(time.RFC3339),
+				Description: fmt.Sprintf("Late fee, installment %d due %s", installment.Number, dueDate),
+				Amount:      rule.LateFee,
+			})
+		}
+
+		// Interest on the part left unpaid between each payment, from the due date on
+		var interest float64
+		from, total := installment.DueDate, Money(0)
+		for _, payment := range payments {
+			if payment.date.After(from) && payment.date.Before(paidOn) {
+				interest += penaltyInterest(installment, expected, total, from, payment.date, rule.PenaltyRate)
+				from = payment.date
+			}
+			if !payment.date.After(from) {
+				total = payment.total
+			}
+		}
+		interest += penaltyInterest(installment, expected, total, from, paidOn, rule.PenaltyRate)
+
+		if amount := Round(interest, interestRounding); amount > 0 {
+			charges = append(charges, Transaction{
+				Type:        TransactionPenaltyInterest,
+				Installment: installment.Number,
+				DateTime:    paidOn.Format(time.RFC3339),
+				Description: fmt.Sprintf("Penalty interest, installment %d due %s, %d days late", installment.Number, dueDate,
+					int(paidOn.Sub(installment.DueDate).Hours()/24)),
+				Amount: amount,
+			})
+		}
+	}
+
+	return charges, nil
+}
+
+// penaltyInterest returns the interest at the annual rate in percent on the part of the
+// installment left unpaid between two dates, given the payments made until then.
+func penaltyInterest(installment Installment, expected, paid Money, from, to time.Time, rate float64) float64 {
+	unpaid := min(max(expected-paid, 0), installment.Amount)
+	return unpaid.Float64() * rate / 100 * to.Sub(from).Hours() / 24 / 365
+}
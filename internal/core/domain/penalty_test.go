@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// newPenaltyLoan creates a loan whose first installment of 304.22 is due on 2026-02-05, charged
+// a late fee of 15.00 and a penalty rate of 10% after 3 days of grace.
+func newPenaltyLoan(t *testing.T) Loan {
+	t.Helper()
+	loan := NewLoan("1", "car", 1000000, 6, 30422)
+	if err := loan.SetCalendar("2026-01-15", 5, 36); err != nil {
+		t.Fatalf("SetCalendar: %v", err)
+	}
+	if err := loan.SetPenaltyRule(&PenaltyRule{GraceDays: 3, LateFee: 1500, PenaltyRate: 10}); err != nil {
+		t.Fatalf("SetPenaltyRule: %v", err)
+	}
+	return loan
+}
+
+// charges returns the type, date and amount of the charges posted on the loan.
+func charges(l *Loan) []string {
+	var posted []string
+	for _, entry := range l.Ledger {
+		if entry.IsCharge() {
+			posted = append(posted, string(entry.Type)+" "+entry.DateTime[:len(time.DateOnly)]+" "+entry.Amount.String())
+		}
+	}
+	return posted
+}
+
+func TestApplyPenalties(t *testing.T) {
+	date := time.Date(2026, time.February, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		payments []Transaction
+		want     []string
+	}{
+		{
+			name:     "paid on time",
+			payments: []Transaction{{DateTime: "2026-02-05", Amount: 30422}},
+		},
+		{
+			name:     "paid within the grace days",
+			payments: []Transaction{{DateTime: "2026-02-08", Amount: 30422}},
+		},
+		{
+			// 304.22 at 10% for 10 days
+			name:     "paid late",
+			payments: []Transaction{{DateTime: "2026-02-15", Amount: 30422}},
+			want:     []string{"late_fee 2026-02-09 15.00", "penalty_interest 2026-02-15 0.83"},
+		},
+		{
+			// Only the 104.22 left unpaid is charged interest
+			name:     "paid in part on time",
+			payments: []Transaction{{DateTime: "2026-02-05", Amount: 20000}, {DateTime: "2026-02-15", Amount: 10422}},
+			want:     []string{"late_fee 2026-02-09 15.00", "penalty_interest 2026-02-15 0.29"},
+		},
+		{
+			// The interest runs until the date the penalties are applied on
+			name: "unpaid",
+			want: []string{"late_fee 2026-02-09 15.00", "penalty_interest 2026-02-20 1.25"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := newPenaltyLoan(t)
+			for _, payment := range tt.payments {
+				if _, err := loan.AddPayment(payment, OverpaymentReject); err != nil {
+					t.Fatalf("AddPayment: %v", err)
+				}
+			}
+			balance := loan.OutstandingBalance()
+
+			posted, reversed, err := loan.ApplyPenalties(date)
+			if err != nil {
+				t.Fatalf("ApplyPenalties: %v", err)
+			}
+			if posted != len(tt.want) || reversed != 0 {
+				t.Errorf("ApplyPenalties posted %d and reversed %d charges, want %d and 0", posted, reversed, len(tt.want))
+			}
+			if got := charges(&loan); !slices.Equal(got, tt.want) {
+				t.Errorf("charges = %q, want %q", got, tt.want)
+			}
+
+			// The charges are owed on top of the balance, and accrue interest until the next payment
+			if got, want := loan.OutstandingBalance(), balance+loan.TotalCharges(); got < want || got > want+PayoffTolerance {
+				t.Errorf("balance = %s, want %s and the interest of the charges", got, want)
+			}
+
+			// Applying them again on the same date changes nothing
+			if posted, reversed, _ := loan.ApplyPenalties(date); posted != 0 || reversed != 0 {
+				t.Errorf("ApplyPenalties again posted %d and reversed %d charges, want none", posted, reversed)
+			}
+		})
+	}
+}
+
+func TestPenaltiesReversed(t *testing.T) {
+	date := time.Date(2026, time.February, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		correct func(t *testing.T, l *Loan, paymentID string)
+	}{
+		{"payment moved on time", func(t *testing.T, l *Loan, paymentID string) {
+			if err := l.ModifyPayment(paymentID, 30422, "", "2026-02-05"); err != nil {
+				t.Fatalf("ModifyPayment: %v", err)
+			}
+		}},
+		{"rule removed", func(t *testing.T, l *Loan, _ string) {
+			if err := l.SetPenaltyRule(nil); err != nil {
+				t.Fatalf("SetPenaltyRule: %v", err)
+			}
+		}},
+		{"loan closed", func(t *testing.T, l *Loan, _ string) {
+			// Only open loans are charged, the charges already posted are kept
+			l.SetStatus(StatusClosed)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := newPenaltyLoan(t)
+			payment, err := loan.AddPayment(Transaction{DateTime: "2026-02-15", Amount: 30422}, OverpaymentReject)
+			if err != nil {
+				t.Fatalf("AddPayment: %v", err)
+			}
+			if posted, _, err := loan.ApplyPenalties(date); err != nil || posted != 2 {
+				t.Fatalf("ApplyPenalties posted %d charges, %v, want 2", posted, err)
+			}
+
+			tt.correct(t, &loan, payment.ID)
+			_, reversed, err := loan.ApplyPenalties(date)
+			if err != nil {
+				t.Fatalf("ApplyPenalties: %v", err)
+			}
+
+			if !loan.GetStatus().IsOpen() {
+				if reversed != 0 || loan.TotalCharges() != 1583 {
+					t.Errorf("ApplyPenalties on a closed loan reversed %d charges and left %s, want 0 and 15.83", reversed, loan.TotalCharges())
+				}
+				return
+			}
+
+			if reversed != 2 {
+				t.Errorf("ApplyPenalties reversed %d charges, want 2", reversed)
+			}
+			if got := charges(&loan); len(got) != 0 || loan.TotalCharges() != 0 {
+				t.Errorf("charges left = %q, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Structure for a change of the interest rate of a variable rate loan
+type RateChange struct {
+	EffectiveDate  string  `json:"effective_date"`            // First day the new rate applies
+	Rate           float64 `json:"rate"`                      // New annual interest rate in percent
+	MonthlyPayment Money   `json:"monthly_payment,omitempty"` // New monthly payment, zero keeps the previous one
+}
+
+// AddRateChange records a change of the interest rate and recalculates the balance with it.
+// A change on the same date as an existing one replaces it.
+func (l *Loan) AddRateChange(change RateChange) error {
+	effective := parseDateTime(change.EffectiveDate)
+	switch {
+	case effective.IsZero():
+		return fmt.Errorf("%w: invalid effective date %q", ErrInvalidRate, change.EffectiveDate)
+	case math.IsNaN(change.Rate) || math.IsInf(change.Rate, 0) || change.Rate < 0:
+		return fmt.Errorf("%w: the interest rate must be zero or positive, got %v", ErrInvalidRate, change.Rate)
+	case change.MonthlyPayment < 0:
+		return fmt.Errorf("%w: the monthly payment cannot be negative, got %s", ErrInvalidAmount, change.MonthlyPayment)
+	}
+
+	changes := slices.DeleteFunc(slices.Clone(l.RateChanges), func(c RateChange) bool {
+		return parseDateTime(c.EffectiveDate).Equal(effective)
+	})
+	changes = append(changes, change)
+	slices.SortStableFunc(changes, func(a, b RateChange) int {
+		return parseDateTime(a.EffectiveDate).Compare(parseDateTime(b.EffectiveDate))
+	})
+
+	// Otherwise the balance grows every month from the change on
+	trial := *l
+	trial.RateChanges = changes
+	monthlyPayment := trial.MonthlyPaymentAt(effective)
+	if interest := MonthlyInterest(l.OutstandingBalance(), change.Rate); monthlyPayment <= interest {
+		return fmt.Errorf("%w: a monthly payment of %s does not cover the %s of interest of a month at %v%%",
+			ErrPaymentTooLow, monthlyPayment, interest, change.Rate)
+	}
+
+	l.RateChanges = changes
+	l.recalculatePayOff()
+	l.updateStatus()
+
+	log.Info().Str("loan_id", l.LoanID).Float64("rate", change.Rate).Str("effective_date", change.EffectiveDate).Msg("Interest rate changed")
+	return nil
+}
+
+// RateAt returns the annual interest rate in effect on the given date.
+func (l *Loan) RateAt(date time.Time) float64 {
+	rate := l.Interest
+	for _, change := range l.RateChanges {
+		if parseDateTime(change.EffectiveDate).After(date) {
+			break
+		}
+		rate = change.Rate
+	}
+	return rate
+}
+
+// MonthlyPaymentAt returns the monthly payment in effect on the given date.
+func (l *Loan) MonthlyPaymentAt(date time.Time) Money {
+	payment := l.MonthlyPayment
+	for _, change := range l.RateChanges {
+		if parseDateTime(change.EffectiveDate).After(date) {
+			break
+		}
+		if change.MonthlyPayment > 0 {
+			payment = change.MonthlyPayment
+		}
+	}
+	return payment
+}
+
+// CurrentRate returns the annual interest rate in effect today.
+func (l *Loan) CurrentRate() float64 {
+	return l.RateAt(time.Now())
+}
+
+// CurrentMonthlyPayment returns the monthly payment in effect today.
+func (l *Loan) CurrentMonthlyPayment() Money {
+	return l.MonthlyPaymentAt(time.Now())
+}
+
+// accruedInterest returns the interest accrued on the balance between two dates, using the
+// rate in effect on each day and an actual/365 day count. When the start is unknown (loans
+// stored before the start date was recorded) a full month of interest is charged.
+func (l *Loan) accruedInterest(balance Money, from, to time.Time) Money {
+	if balance <= 0 {
+		return 0
+	}
+
+	if from.IsZero() || to.IsZero() {
+		return MonthlyInterest(balance, l.RateAt(to))
+	}
+
+	if !to.After(from) {
+		return 0
+	}
+
+	// Split the period at every rate change in between
+	var interest float64
+	start, rate := from, l.RateAt(from)
+	for _, change := range l.RateChanges {
+		effective := parseDateTime(change.EffectiveDate)
+		if !effective.After(start) {
+			continue
+		}
+		if !effective.Before(to) {
+			break
+		}
+		interest += balance.Float64() * rate / 100 * effective.Sub(start).Hours() / 24 / 365
+		start, rate = effective, change.Rate
+	}
+	interest += balance.Float64() * rate / 100 * to.Sub(start).Hours() / 24 / 365
+
+	return Round(interest, interestRounding)
+}
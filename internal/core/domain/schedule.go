@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxSchedulePeriods caps the schedule length so a payment that barely covers
+// the interest cannot produce an endless schedule (100 years of installments).
+const maxSchedulePeriods = 1200
+
+// Structure for each future installment in the amortization schedule
+type SchedulePeriod struct {
+	Number         int       `json:"number"`
+	DueDate        time.Time `json:"due_date"`
+	Rate           float64   `json:"rate"` // Annual interest rate of the period
+	OpeningBalance Money     `json:"opening_balance"`
+	Payment        Money     `json:"payment"`
+	Extra          Money     `json:"extra,omitempty"` // Part of the payment above the monthly payment
+	Interest       Money     `json:"interest"`
+	Principal      Money     `json:"principal"`
+	ClosingBalance Money     `json:"closing_balance"`
+}
+
+// Structure for an extra payment of principal made on a given date
+type Prepayment struct {
+	Date   time.Time `json:"date"`
+	Amount Money     `json:"amount"`
+}
+
+// Structure to represent the full amortization schedule of a loan
+type Schedule struct {
+	Periods        []SchedulePeriod `json:"periods"`
+	TotalPayment   Money            `json:"total_payment"`
+	TotalInterest  Money            `json:"total_interest"`
+	TotalPrincipal Money            `json:"total_principal"`
+}
+
+// AmortizationSchedule builds every future installment of the loan, starting with the
+// first one due after the given date, until the outstanding balance is paid off.
+func (l *Loan) AmortizationSchedule(from time.Time) (Schedule, error) {
+	return l.ScheduleWithPrepayments(from, 0, nil)
+}
+
+// ScheduleWithPrepayments builds the amortization schedule paying, on top of every
+// installment, the monthly extra and the prepayments dated up to its due date.
+// Prepayments dated after the loan is paid off are ignored.
+func (l *Loan) ScheduleWithPrepayments(from time.Time, monthlyExtra Money, prepayments []Prepayment) (Schedule, error) {
+	var schedule Schedule
+
+	balance := l.OutstandingBalance()
+	if balance <= 0 {
+		return schedule, nil
+	}
+
+	if l.MonthlyPaymentAt(from) <= 0 {
+		return schedule, fmt.Errorf("%w: the monthly payment must be greater than zero, got %s", ErrInvalidAmount, l.MonthlyPaymentAt(from))
+	}
+
+	if interest := MonthlyInterest(balance, l.RateAt(from)); l.MonthlyPaymentAt(from) <= interest {
+		return schedule, fmt.Errorf("%w: a monthly payment of %s does not cover the %s of interest of a month",
+			ErrPaymentTooLow, l.MonthlyPaymentAt(from), interest)
+	}
+
+	dueDates := l.dueDatesFrom(from)
+	for n := 1; balance > 0 && n <= maxSchedulePeriods; n++ {
+		dueDate := dueDates(n)
+		periodStart := from
+		if n > 1 {
+			periodStart = dueDates(n - 1)
+		}
+
+		// Each installment uses the rate and monthly payment in effect when its period starts
+		rate := l.RateAt(periodStart)
+		monthlyPayment := l.MonthlyPaymentAt(periodStart)
+		interest := MonthlyInterest(balance, rate)
+
+		// Each prepayment is paid with the first installment due on or after its date
+		extra := monthlyExtra
+		for _, prepayment := range prepayments {
+			if !prepayment.Date.After(dueDate) && (n == 1 || prepayment.Date.After(periodStart)) {
+				extra += prepayment.Amount
+			}
+		}
+		payment := monthlyPayment + extra
+
+		// The last installment only pays what is left
+		if payment > balance+interest {
+			payment = balance + interest
+			extra = max(payment-monthlyPayment, 0)
+		}
+		principal := payment - interest
+
+		period := SchedulePeriod{
+			Number:         n,
+			DueDate:        dueDate,
+			Rate:           rate,
+			OpeningBalance: balance,
+			Payment:        payment,
+			Extra:          extra,
+			Interest:       interest,
+			Principal:      principal,
+			ClosingBalance: balance - principal,
+		}
+		schedule.Periods = append(schedule.Periods, period)
+
+		schedule.TotalPayment += payment
+		schedule.TotalInterest += interest
+		schedule.TotalPrincipal += principal
+
+		balance = period.ClosingBalance
+	}
+
+	return schedule, nil
+}
+
+// OutstandingBalance returns the amount still owed, derived by replaying the ledger.
+func (l *Loan) OutstandingBalance() Money {
+	return l.Replay().Balance
+}
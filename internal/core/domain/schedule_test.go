@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduleDueDates(t *testing.T) {
+	from := time.Date(2027, time.January, 31, 7, 17, 59, 800515384, time.UTC)
+
+	withCalendar := NewLoan("1", "calendar", 1000000, 6, 30422)
+	if err := withCalendar.SetCalendar("2026-05-10", 5, 36); err != nil {
+		t.Fatalf("SetCalendar: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		loan Loan
+		from time.Time
+		want []string
+	}{
+		// Without a calendar the installments are due on the day of the month of the date,
+		// moved to the last day of shorter months
+		{"month end", NewLoan("1", "no calendar", 1000000, 6, 30422), from, []string{"2027-02-28", "2027-03-31", "2027-04-30"}},
+		{"calendar", withCalendar, from, []string{"2027-02-05", "2027-03-05", "2027-04-05"}},
+		{"due today", withCalendar, time.Date(2027, time.February, 5, 18, 0, 0, 0, time.UTC), []string{"2027-02-05", "2027-03-05"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := tt.loan.AmortizationSchedule(tt.from)
+			if err != nil {
+				t.Fatalf("AmortizationSchedule: %v", err)
+			}
+
+			for i, want := range tt.want {
+				got := schedule.Periods[i].DueDate
+				if got.Format(time.DateOnly) != want || !got.Equal(got.Truncate(24*time.Hour)) {
+					t.Errorf("installment %d due %s, want %s at midnight", i+1, got.Format(time.RFC3339Nano), want)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		payment string
+		wantErr error
+	}{
+		{"no monthly payment", "0", ErrInvalidAmount},
+		{"below the interest", "49.99", ErrPaymentTooLow},
+		{"equal to the interest", "50", ErrPaymentTooLow},
+	}
+
+	// 10000 at 6% accrues 50.00 of interest a month
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := NewLoan("1", "test", 1000000, 6, mustMoney(t, tt.payment))
+			if _, err := loan.AmortizationSchedule(time.Now()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("AmortizationSchedule error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
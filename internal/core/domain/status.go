@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// LoanStatus is the lifecycle state of a loan.
+type LoanStatus string
+
+const (
+	StatusActive     LoanStatus = "active"      // The loan is being paid
+	StatusPaidOff    LoanStatus = "paid_off"    // The balance reached zero
+	StatusClosed     LoanStatus = "closed"      // Closed by hand, no more payments are accepted
+	StatusArchived   LoanStatus = "archived"    // Hidden from the loan list
+	StatusWrittenOff LoanStatus = "written_off" // The remaining balance will never be paid
+)
+
+// loanTransitions lists the states a loan can be moved to by hand from each state.
+// Going from active to paid off and back happens automatically as the balance changes.
+var loanTransitions = map[LoanStatus][]LoanStatus{
+	StatusActive:     {StatusClosed, StatusWrittenOff},
+	StatusPaidOff:    {StatusClosed, StatusArchived},
+	StatusClosed:     {StatusActive, StatusArchived},
+	StatusWrittenOff: {StatusActive, StatusArchived},
+	StatusArchived:   {StatusActive},
+}
+
+// ParseLoanStatus validates a loan status name.
+func ParseLoanStatus(name string) (LoanStatus, error) {
+	status := LoanStatus(name)
+	if _, ok := loanTransitions[status]; !ok {
+		return "", fmt.Errorf("unknown loan status %q, use active, paid_off, closed, archived or written_off", name)
+	}
+	return status, nil
+}
+
+// IsOpen reports whether the payments of a loan in this state can still be changed.
+func (s LoanStatus) IsOpen() bool {
+	return s == StatusActive || s == StatusPaidOff
+}
+
+// GetStatus returns the status of the loan. Loans stored before the status existed are active.
+func (l *Loan) GetStatus() LoanStatus {
+	if l.Status == "" {
+		return StatusActive
+	}
+	return l.Status
+}
+
+// NextStatuses returns the states the loan can be moved to by hand.
+func (l *Loan) NextStatuses() []LoanStatus {
+	return loanTransitions[l.GetStatus()]
+}
+
+// CanTransition reports whether the loan can be moved to the given state by hand.
+func (l *Loan) CanTransition(status LoanStatus) bool {
+	return slices.Contains(l.NextStatuses(), status)
+}
+
+// SetStatus moves the loan to the given state. A loan set back to active is marked as
+// paid off right away when its balance is already zero.
+func (l *Loan) SetStatus(status LoanStatus) {
+	l.Status = status
+	l.updateStatus()
+	log.Info().Str("loan_id", l.LoanID).Str("status", string(l.Status)).Msg("Loan status changed")
+}
+
+// updateStatus moves an active loan to paid off when its balance reaches zero (within the
+// rounding tolerance), and back to active when a changed payment leaves something to pay.
+func (l *Loan) updateStatus() {
+	paidOff := l.isPaidOff()
+
+	switch status := l.GetStatus(); {
+	case status == StatusActive && paidOff:
+		l.Status = StatusPaidOff
+		log.Info().Str("loan_id", l.LoanID).Msg("Loan paid off")
+	case status == StatusPaidOff && !paidOff:
+		l.Status = StatusActive
+		log.Info().Str("loan_id", l.LoanID).Msg("Loan has a balance again, it is active")
+	default:
+		l.Status = status
+	}
+}
+
+// UpdateLoanStatuses sets the status of the loans stored before it existed and returns
+// how many loans were changed.
+func (u *User) UpdateLoanStatuses() int {
+	changed := 0
+	for i := range u.Loans {
+		loan := &u.Loans[i]
+		before := loan.Status
+		loan.updateStatus()
+		if loan.Status != before {
+			changed++
+		}
+	}
+	return changed
+}
+
+// CurrentLoans returns the loans of the user that are not archived.
+func (u *User) CurrentLoans() []Loan {
+	loans := []Loan{}
+	for _, loan := range u.Loans {
+		if loan.GetStatus() != StatusArchived {
+			loans = append(loans, loan)
+		}
+	}
+	return loans
+}
+
+// ArchivedLoans returns the archived loans of the user.
+func (u *User) ArchivedLoans() []Loan {
+	loans := []Loan{}
+	for _, loan := range u.Loans {
+		if loan.GetStatus() == StatusArchived {
+			loans = append(loans, loan)
+		}
+	}
+	return loans
+}
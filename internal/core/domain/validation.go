@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// highInterestRate is the annual rate, in percent, above which a loan is still accepted
+// but a warning is logged, since it is most likely a typo.
+const highInterestRate = 100
+
+// ValidateLoan checks the terms of a new loan. It rejects the values that make the loan
+// impossible to pay off and logs a warning for the ones that are only suspicious.
+func ValidateLoan(loanName string, amount Money, interest float64, monthlyPayment Money) error {
+	switch {
+	case strings.TrimSpace(loanName) == "":
+		return fmt.Errorf("%w: the loan name cannot be empty", ErrInvalidLoan)
+	case amount <= 0:
+		return fmt.Errorf("%w: the loan amount must be greater than zero, got %s", ErrInvalidAmount, amount)
+	case monthlyPayment <= 0:
+		return fmt.Errorf("%w: the monthly payment must be greater than zero, got %s", ErrInvalidAmount, monthlyPayment)
+	case math.IsNaN(interest) || math.IsInf(interest, 0) || interest < 0:
+		return fmt.Errorf("%w: the interest rate must be zero or positive, got %v", ErrInvalidRate, interest)
+	}
+
+	// Otherwise the balance grows every month and the loan is never paid off
+	firstInterest := MonthlyInterest(amount, interest)
+	if monthlyPayment <= firstInterest {
+		return fmt.Errorf("%w: a monthly payment of %s does not cover the %s of interest of the first month",
+			ErrPaymentTooLow, monthlyPayment, firstInterest)
+	}
+
+	if interest > highInterestRate {
+		log.Warn().Float64("interest", interest).Msg("The interest rate is above 100%, check it is the annual rate in percent")
+	}
+	if monthlyPayment > amount+firstInterest {
+		log.Warn().Stringer("monthly_payment", monthlyPayment).Stringer("amount", amount).
+			Msg("The monthly payment is greater than the loan amount, it will be paid off with the first payment")
+	}
+
+	return nil
+}
+
+// Validate checks the terms of the loan with ValidateLoan.
+func (l *Loan) Validate() error {
+	return ValidateLoan(l.LoanName, l.Amount, l.Interest, l.MonthlyPayment)
+}
+
+// warnUnpaidInterest logs a warning when a payment does not cover the interest accrued
+// before it, since the unpaid interest makes the balance grow.
+func (l *Loan) warnUnpaidInterest(paymentID string) {
+	for _, line := range l.Replay().Lines {
+		if line.ID != paymentID || line.Type != TransactionPayment || line.Principal >= 0 {
+			continue
+		}
+
+		log.Warn().Str("loan_id", l.LoanID).Stringer("amount", line.Amount).Stringer("interest", line.Interest).
+			Msg("The payment does not cover the accrued interest, the unpaid interest is added to the balance")
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+
+	"github.com/rs/zerolog/log"
+)
+
+// UpcomingDays is how many days ahead an installment is reported as upcoming.
+const UpcomingDays = 7
+
+// Structure to represent where a loan stands against its payment calendar on a date
+type DueStatus struct {
+	LoanID              string       `json:"loan_id"`
+	LoanName            string       `json:"loan_name"`
+	Date                time.Time    `json:"date"`                 // Date the status was computed for
+	NextDueDate         time.Time    `json:"next_due_date"`        // Zero when every installment is past due
+	NextDueAmount       domain.Money `json:"next_due_amount"`      // Part of the next installment not paid in advance
+	InstallmentsDue     int          `json:"installments_due"`     // Installments due before the date
+	InstallmentsPaid    int          `json:"installments_paid"`    // Installments covered by the payments made
+	OverdueInstallments int          `json:"overdue_installments"` // Installments due before the date and not paid
+	OverdueSince        time.Time    `json:"overdue_since"`        // Due date of the oldest unpaid installment
+	Arrears             domain.Money `json:"arrears"`              // Amount due before the date and not paid
+	Charges             domain.Money `json:"charges"`              // Late fees and penalty interest posted on the loan
+	MaturityDate        time.Time    `json:"maturity_date"`        // Due date of the last installment of the term
+}
+
+// IsOverdue reports whether the loan has installments due and not paid.
+func (d DueStatus) IsOverdue() bool {
+	return d.OverdueInstallments > 0
+}
+
+// IsUpcoming reports whether the next installment is due within the given number of days.
+func (d DueStatus) IsUpcoming(days int) bool {
+	return !d.NextDueDate.IsZero() && d.NextDueAmount > 0 && d.NextDueDate.Before(startOfDay(d.Date).AddDate(0, 0, days+1))
+}
+
+// LoanDueStatus compares the payments made on the loan up to the given date against its payment
+// calendar. The payments cover the installments in order, so a payment made in advance counts
+// for the next installment and a short payment leaves the installment overdue. An installment
+// is overdue from the day after its due date.
+func LoanDueStatus(loan domain.Loan, date time.Time) (DueStatus, error) {
+	status := DueStatus{
+		LoanID:       loan.LoanID,
+		LoanName:     loan.LoanName,
+		Date:         date,
+		Charges:      loan.TotalCharges(),
+		MaturityDate: loan.MaturityDate(),
+	}
+
+	installments, err := loan.Installments()
+	if err != nil || len(installments) == 0 {
+		return status, err
+	}
+
+	// Only the active loans still expect payments
+	if loan.GetStatus() != domain.StatusActive {
+		return status, nil
+	}
+
+	today := startOfDay(date)
+	left := loan.PaidUntil(date)
+	for _, installment := range installments {
+		pastDue := installment.DueDate.Before(today)
+		if pastDue {
+			status.InstallmentsDue++
+		}
+
+		paid := min(left, installment.Amount)
+		left -= paid
+		if paid == installment.Amount {
+			status.InstallmentsPaid++
+			continue
+		}
+
+		if !pastDue {
+			// The first installment not past due is the next one
+			if status.NextDueDate.IsZero() {
+				status.NextDueDate = installment.DueDate
+				status.NextDueAmount = installment.Amount - paid
+			}
+			continue
+		}
+
+		status.OverdueInstallments++
+		status.Arrears += installment.Amount - paid
+		if status.OverdueSince.IsZero() {
+			status.OverdueSince = installment.DueDate
+		}
+	}
+
+	// The rounding of the interest may leave the schedule a few cents above what the loan owes
+	if payoff := loan.PayoffAmount(date.Format(time.RFC3339)); status.Arrears > payoff {
+		status.Arrears = payoff
+	}
+	if status.Arrears <= domain.PayoffTolerance {
+		status.Arrears, status.OverdueInstallments, status.OverdueSince = 0, 0, time.Time{}
+	}
+
+	return status, nil
+}
+
+// SetLoanCalendar sets the start date, the due day of the installments and the term of a loan.
+func (s *UserService) SetLoanCalendar(userName string, loanID string, startDate string, dueDay, term int) error {
+	selectedLoan, err := s.lookupLoan(userName, loanID)
+	if err != nil {
+		return err
+	}
+
+	if status := selectedLoan.GetStatus(); !status.IsOpen() {
+		return fmt.Errorf("%w: loan %s is %s, reopen it to change its calendar", ErrLoanNotOpen, loanID, status)
+	}
+
+	if err := selectedLoan.SetCalendar(startDate, dueDay, term); err != nil {
+		return err
+	}
+
+	applyPenalties(selectedLoan, time.Now())
+	return nil
+}
+
+// DueStatuses returns the status against their payment calendar of the active loans that have one.
+// Loans whose calendar cannot be computed are logged and skipped.
+func DueStatuses(loans []domain.Loan, date time.Time) []DueStatus {
+	var statuses []DueStatus
+	for _, loan := range loans {
+		if !loan.HasCalendar() || loan.GetStatus() != domain.StatusActive {
+			continue
+		}
+
+		status, err := LoanDueStatus(loan, date)
+		if err != nil {
+			log.Error().Err(err).Str("loan_id", loan.LoanID).Msg("Could not calculate the payment calendar of the loan.")
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// DueReminders returns the status of the loans with overdue installments or an installment due
+// within the given number of days.
+func DueReminders(loans []domain.Loan, date time.Time, days int) []DueStatus {
+	var reminders []DueStatus
+	for _, status := range DueStatuses(loans, date) {
+		if status.IsOverdue() || status.IsUpcoming(days) {
+			reminders = append(reminders, status)
+		}
+	}
+	return reminders
+}
+
+// startOfDay returns the midnight that starts the day of the date.
+func startOfDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
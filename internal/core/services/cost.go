@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+)
+
+// Structure to represent the true cost of a loan as it was granted, fees included
+type LoanCost struct {
+	LoanID         string       `json:"loan_id,omitempty"`
+	LoanName       string       `json:"loan_name"`
+	Amount         domain.Money `json:"amount"`
+	Interest       float64      `json:"interest"`       // Nominal annual rate
+	EffectiveRate  float64      `json:"effective_rate"` // Nominal rate compounded monthly, without fees
+	APR            float64      `json:"apr"`            // Annual percentage rate (TAE), fees included
+	MonthlyPayment domain.Money `json:"monthly_payment"`
+	MonthlyFees    domain.Money `json:"monthly_fees"` // Recurring fees paid with every installment
+	Installments   int          `json:"installments"`
+	PayoffDate     time.Time    `json:"payoff_date"`
+	TotalInterest  domain.Money `json:"total_interest"`
+	UpfrontFees    domain.Money `json:"upfront_fees"`
+	TotalFees      domain.Money `json:"total_fees"` // Upfront fees plus the recurring fees of every installment
+	TotalCost      domain.Money `json:"total_cost"` // Interest plus fees
+	TotalPaid      domain.Money `json:"total_paid"` // Amount plus total cost
+}
+
+// CalculateCost computes the interest, fees, effective rate and APR of the loan with its
+// original terms, as if no payment had been made yet.
+func CalculateCost(loan domain.Loan) (LoanCost, error) {
+	cost := LoanCost{
+		LoanID:         loan.LoanID,
+		LoanName:       loan.LoanName,
+		Amount:         loan.Amount,
+		Interest:       loan.Interest,
+		EffectiveRate:  domain.EffectiveAnnualRate(loan.Interest),
+		MonthlyPayment: loan.MonthlyPayment,
+		MonthlyFees:    loan.MonthlyFees(),
+		UpfrontFees:    loan.UpfrontFees(),
+	}
+
+	schedule, err := loan.OriginalSchedule()
+	if err != nil {
+		return cost, err
+	}
+
+	if cost.APR, err = loan.APR(); err != nil {
+		return cost, err
+	}
+
+	cost.Installments = len(schedule.Periods)
+	cost.PayoffDate = payoffDate(schedule)
+	cost.TotalInterest = schedule.TotalInterest
+	cost.TotalFees = cost.UpfrontFees + cost.MonthlyFees*domain.Money(cost.Installments)
+	cost.TotalCost = cost.TotalInterest + cost.TotalFees
+	cost.TotalPaid = cost.Amount + cost.TotalCost
+
+	return cost, nil
+}
+
+// NewOffer validates the terms and fees of a loan offer and builds a loan that is not stored,
+// to work out its cost before creating it.
+func NewOffer(name string, amount domain.Money, interest float64, monthlyPayment domain.Money, fees ...domain.Fee) (domain.Loan, error) {
+	if err := domain.ValidateLoan(name, amount, interest, monthlyPayment); err != nil {
+		return domain.Loan{}, err
+	}
+
+	offer := domain.NewLoan("", name, amount, interest, monthlyPayment)
+	for _, fee := range fees {
+		if err := offer.AddFee(fee); err != nil {
+			return domain.Loan{}, err
+		}
+	}
+	return offer, nil
+}
+
+// AddFeeToLoan records a fee charged by the lender of an active or paid off loan.
+func (s *UserService) AddFeeToLoan(userName string, loanID string, fee domain.Fee) error {
+	selectedLoan, err := s.lookupLoan(userName, loanID)
+	if err != nil {
+		return err
+	}
+
+	if status := selectedLoan.GetStatus(); !status.IsOpen() {
+		return fmt.Errorf("%w: loan %s is %s, reopen it to add fees", ErrLoanNotOpen, loanID, status)
+	}
+
+	return selectedLoan.AddFee(fee)
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+)
+
+// Errors returned by the UserService. They are wrapped with the details of the failure,
+// so use errors.Is to check for them.
+var (
+	// ErrUserNotFound is returned when there is no user with the given name.
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrUserExists is returned when creating a user whose name is already taken.
+	ErrUserExists = errors.New("user already exists")
+
+	// ErrLoanNotFound is returned when the user has no loan with the given ID.
+	ErrLoanNotFound = errors.New("loan not found")
+
+	// ErrLoanNotOpen is returned when changing the payments of a closed, written off or archived loan.
+	ErrLoanNotOpen = errors.New("loan is not open")
+
+	// ErrInvalidTransition is returned when a loan cannot be moved to the requested status.
+	ErrInvalidTransition = errors.New("invalid loan status change")
+)
+
+// Errors of the domain returned unchanged by the UserService.
+var (
+	ErrPaymentNotFound    = domain.ErrPaymentNotFound
+	ErrLoanFullyPaid      = domain.ErrLoanFullyPaid
+	ErrOverpayment        = domain.ErrOverpayment
+	ErrInvalidAmount      = domain.ErrInvalidAmount
+	ErrInvalidRate        = domain.ErrInvalidRate
+	ErrInvalidLoan        = domain.ErrInvalidLoan
+	ErrPaymentBeforeStart = domain.ErrPaymentBeforeStart
+	ErrPaymentTooLow      = domain.ErrPaymentTooLow
+	ErrAutomaticEntry     = domain.ErrAutomaticEntry
+)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/zapisanchez/loanMgr/internal/adapters/input"
 	"github.com/zapisanchez/loanMgr/internal/core/domain"
@@ -16,12 +17,16 @@ import (
 func PrintLoanSummary(loan domain.Loan) {
 	fmt.Println("Loan ID:", loan.LoanID)
 	fmt.Println("Initial Loan Amount:", loan.Amount)
-	fmt.Println("Remaining Loan Amount:", loan.RemainingAmount)
-	fmt.Println("Total Paid:", loan.TotalPaid)
-	fmt.Println("Monthly Payment:", loan.MonthlyPayment)
+	statement := loan.Replay()
+	fmt.Println("Remaining Loan Amount:", statement.Balance)
+	fmt.Println("Total Paid:", statement.TotalPaid)
+	fmt.Println("Interest Paid:", statement.InterestPaid)
+	fmt.Println("Monthly Payment:", loan.CurrentMonthlyPayment())
 	fmt.Println("Payments:")
-	for _, payment := range loan.Payments {
-		fmt.Printf(" - Date: %s, Amount: %s\n", payment.DateTime, strconv.FormatFloat(payment.Amount, 'f', 2, 64))
+	for _, line := range statement.Lines {
+		if line.Type == domain.TransactionPayment {
+			fmt.Printf(" - Date: %s, Amount: %s, Interest: %s, Principal: %s\n", line.DateTime, line.Amount, line.Interest, line.Principal)
+		}
 	}
 }
 
@@ -38,50 +43,10 @@ func PrintAllLoans(loans []domain.Loan) {
 
 	input.ClearScreen()
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{
-		"Loan Name",
-		"Loan ID",
-		"Amount",
-		"Remaining Amount",
-		"Total Paid",
-		"Interest Rate",
-		"Monthly Payment",
-		"Months to Pay Off",
-		"Years to Pay Off",
-	})
-	for _, loan := range loans {
-
-		table.Append([]string{
-			loan.LoanName,
-			loan.LoanID,
-			fmt.Sprintf("%.2f", loan.Amount),
-			fmt.Sprintf("%.2f", loan.RemainingAmount),
-			fmt.Sprintf("%.2f", loan.TotalPaid),
-			fmt.Sprintf("%.2f", loan.Interest),
-			fmt.Sprintf("%.2f", loan.MonthlyPayment),
-			fmt.Sprintf("%.2f", loan.TimePaidOff),
-			fmt.Sprintf("%.2f", loan.TimePaidOff/12),
-		})
-
-		table.SetHeaderColor(
-			tablewriter.Colors{tablewriter.Bold, tablewriter.BgGreenColor},
-			tablewriter.Colors{tablewriter.FgHiRedColor, tablewriter.Bold, tablewriter.BgBlackColor},
-			tablewriter.Colors{tablewriter.BgCyanColor, tablewriter.FgWhiteColor},
-			tablewriter.Colors{},
-			tablewriter.Colors{},
-			tablewriter.Colors{},
-			tablewriter.Colors{},
-			tablewriter.Colors{},
-			tablewriter.Colors{},
-		)
-
+	if err := WriteLoans(os.Stdout, loans, FormatTable); err != nil {
+		log.Error().Err(err).Msg("Error printing loans")
 	}
 
-	table.SetAutoFormatHeaders(true)
-	// table.SetBorder(false)
-	table.Render()
-
 	// Ask the user if they want to go back to the main menu or exit
 	log.Info().Msg("Press 'Enter' to go back to the main")
 	_ = input.GetUserInput()
@@ -90,46 +55,171 @@ func PrintAllLoans(loans []domain.Loan) {
 
 // PrintPaymentHistory prints the payment history for a specific loan.
 func PrintPaymentHistory(loan domain.Loan) {
-	if len(loan.Payments) == 0 {
+	if len(loan.Ledger) == 0 {
 		fmt.Println("No payment history found for this loan.")
 		return
 	}
 
 	input.ClearScreen()
 
-	fmt.Printf("Payment history for Loan: %s (%s)\n", loan.LoanName, loan.LoanID)
+	if err := WritePaymentHistory(os.Stdout, loan, FormatTable); err != nil {
+		log.Error().Err(err).Msg("Error printing payment history")
+	}
+}
+
+// PrintAmortizationSchedule prints every future installment of a loan with its interest and principal split.
+func PrintAmortizationSchedule(loan domain.Loan) {
+	schedule, err := loan.AmortizationSchedule(time.Now())
+	if err != nil {
+		log.Error().Err(err).Str("loan_id", loan.LoanID).Msg("Error calculating amortization schedule")
+		return
+	}
+
+	if len(schedule.Periods) == 0 {
+		fmt.Println("This loan has no pending installments.")
+		return
+	}
+
+	input.ClearScreen()
+
+	fmt.Printf("Amortization schedule for Loan: %s (%s)\n", loan.LoanName, loan.LoanID)
 	fmt.Println()
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Date", "Description", "Amount"})
+	table.SetHeader([]string{"#", "Due Date", "Opening Balance", "Payment", "Interest", "Principal", "Closing Balance"})
 
 	table.SetHeaderColor(
 		tablewriter.Colors{tablewriter.Bold, tablewriter.BgGreenColor},
 		tablewriter.Colors{tablewriter.FgHiRedColor, tablewriter.Bold, tablewriter.BgBlackColor},
-		tablewriter.Colors{tablewriter.BgCyanColor, tablewriter.FgWhiteColor})
+		tablewriter.Colors{tablewriter.BgCyanColor, tablewriter.FgWhiteColor},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{})
 
-	for _, payment := range loan.Payments {
-		table.Append([]string{payment.DateTime, payment.Description, fmt.Sprintf("%.2f €", payment.Amount)})
+	for _, period := range schedule.Periods {
+		table.Append([]string{
+			strconv.Itoa(period.Number),
+			period.DueDate.Format(time.DateOnly),
+			formatMoney(period.OpeningBalance),
+			formatMoney(period.Payment),
+			formatMoney(period.Interest),
+			formatMoney(period.Principal),
+			formatMoney(period.ClosingBalance),
+		})
 	}
 
 	table.SetAutoFormatHeaders(true)
-	table.SetFooter([]string{"", "Total Paid", fmt.Sprintf("%.2f €", loan.TotalPaid)})
+	table.SetFooter([]string{
+		"",
+		"Totals",
+		"",
+		formatMoney(schedule.TotalPayment),
+		formatMoney(schedule.TotalInterest),
+		formatMoney(schedule.TotalPrincipal),
+		"",
+	})
 	table.SetFooterColor(
 		tablewriter.Colors{},
 		tablewriter.Colors{tablewriter.Bold},
-		tablewriter.Colors{tablewriter.FgHiRedColor})
+		tablewriter.Colors{},
+		tablewriter.Colors{tablewriter.FgHiRedColor},
+		tablewriter.Colors{tablewriter.FgHiRedColor},
+		tablewriter.Colors{tablewriter.FgHiRedColor},
+		tablewriter.Colors{})
 
-	// table.SetAlignment(tablewriter.ALIGN_RIGHT)
 	table.SetBorder(false)
 
 	table.Render()
 
-	totalTable := tablewriter.NewWriter(os.Stdout)
-	totalTable.SetHeader([]string{"Total Paid", "Remaining Balance"})
-	totalTable.Append([]string{fmt.Sprintf("%.2f €", loan.TotalPaid), fmt.Sprintf("%.2f €", loan.RemainingAmount)})
-	totalTable.SetAutoFormatHeaders(true)
-	totalTable.SetAlignment(tablewriter.ALIGN_RIGHT)
-	totalTable.Render()
-
 	fmt.Println()
 }
+
+// PrintSimulation prints the result of a prepayment simulation.
+func PrintSimulation(simulation Simulation) {
+	input.ClearScreen()
+
+	if err := WriteSimulation(os.Stdout, simulation, FormatTable); err != nil {
+		log.Error().Err(err).Msg("Error printing simulation")
+	}
+
+	// Ask the user if they want to go back to the main menu or exit
+	log.Info().Msg("Press 'Enter' to go back to the main")
+	_ = input.GetUserInput()
+	input.ClearScreen()
+}
+
+// PrintPayoffPlans prints the payoff plans side by side and the month by month payments of each one.
+func PrintPayoffPlans(plans []PayoffPlan) {
+	input.ClearScreen()
+
+	if err := WritePayoffPlans(os.Stdout, plans, FormatTable); err != nil {
+		log.Error().Err(err).Msg("Error printing payoff plans")
+	}
+
+	// Ask the user if they want to go back to the main menu or exit
+	log.Info().Msg("Press 'Enter' to go back to the main")
+	_ = input.GetUserInput()
+	input.ClearScreen()
+}
+
+// PrintRateChangeImpact prints how a change of the interest rate affects a loan.
+func PrintRateChangeImpact(impact RateChangeImpact) {
+	input.ClearScreen()
+
+	if err := WriteRateChangeImpact(os.Stdout, impact, FormatTable); err != nil {
+		log.Error().Err(err).Msg("Error printing rate change")
+	}
+
+	// Ask the user if they want to go back to the main menu or exit
+	log.Info().Msg("Press 'Enter' to go back to the main")
+	_ = input.GetUserInput()
+	input.ClearScreen()
+}
+
+// PrintLoanCosts prints the cost of the loans side by side.
+func PrintLoanCosts(costs []LoanCost) {
+	input.ClearScreen()
+
+	if err := WriteLoanCosts(os.Stdout, costs, FormatTable); err != nil {
+		log.Error().Err(err).Msg("Error printing loan costs")
+	}
+
+	// Ask the user if they want to go back to the main menu or exit
+	log.Info().Msg("Press 'Enter' to go back to the main")
+	_ = input.GetUserInput()
+	input.ClearScreen()
+}
+
+// PrintOfferComparison prints the cost of the offers side by side, highlighting the cheapest one.
+func PrintOfferComparison(comparison OfferComparison) {
+	input.ClearScreen()
+
+	if err := WriteOfferComparison(os.Stdout, comparison, FormatTable); err != nil {
+		log.Error().Err(err).Msg("Error printing offer comparison")
+	}
+
+	// Ask the user if they want to go back to the main menu or exit
+	log.Info().Msg("Press 'Enter' to go back to the main")
+	_ = input.GetUserInput()
+	input.ClearScreen()
+}
+
+// PrintDueStatuses prints where the loans stand against their payment calendar.
+func PrintDueStatuses(statuses []DueStatus) {
+	input.ClearScreen()
+
+	if err := WriteDueStatuses(os.Stdout, statuses, FormatTable); err != nil {
+		log.Error().Err(err).Msg("Error printing payment calendar")
+	}
+
+	// Ask the user if they want to go back to the main menu or exit
+	log.Info().Msg("Press 'Enter' to go back to the main")
+	_ = input.GetUserInput()
+	input.ClearScreen()
+}
+
+// PrintDueBanner prints the overdue installments and the ones due in the next days, above the main menu.
+func PrintDueBanner(loans []domain.Loan) {
+	WriteDueBanner(os.Stdout, DueReminders(loans, time.Now(), UpcomingDays))
+}
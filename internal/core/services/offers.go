@@ -0,0 +1,83 @@
+package services
+
+import (
+	"cmp"
+	"fmt"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+)
+
+// Limits of the number of offers compared at once
+const (
+	MinOffers = 2
+	MaxOffers = 5
+)
+
+// Structure to represent a loan offer to compare before signing. Either the term or the
+// monthly payment is given, the other one is worked out from it.
+type Offer struct {
+	Name           string       `json:"name"`
+	Amount         domain.Money `json:"amount"`
+	Interest       float64      `json:"interest"`
+	Term           int          `json:"term,omitempty"` // Months to pay off the loan
+	MonthlyPayment domain.Money `json:"monthly_payment,omitempty"`
+	Fees           []domain.Fee `json:"fees,omitempty"`
+}
+
+// Structure to represent the cost of several offers side by side
+type OfferComparison struct {
+	Offers   []LoanCost `json:"offers"`
+	Cheapest int        `json:"cheapest"` // Index of the offer with the lowest total cost
+}
+
+// Loan builds the loan of the offer, which is not stored. When the offer has a term instead of a
+// monthly payment, the payment is the one that pays the loan off in that term.
+func (o Offer) Loan() (domain.Loan, error) {
+	monthlyPayment := o.MonthlyPayment
+	if monthlyPayment == 0 {
+		if o.Term <= 0 {
+			return domain.Loan{}, fmt.Errorf("%w: offer %q needs a term or a monthly payment", ErrInvalidLoan, o.Name)
+		}
+
+		payment, err := domain.PaymentForTerm(o.Amount, o.Interest, o.Term)
+		if err != nil {
+			return domain.Loan{}, fmt.Errorf("offer %q: %w", o.Name, err)
+		}
+		monthlyPayment = payment
+	}
+
+	loan, err := NewOffer(o.Name, o.Amount, o.Interest, monthlyPayment, o.Fees...)
+	if err != nil {
+		return loan, fmt.Errorf("offer %q: %w", o.Name, err)
+	}
+	return loan, nil
+}
+
+// CompareOffers works out the cost of each offer and finds the cheapest one, the one with the
+// lowest total cost or, on a tie, the lowest APR. Nothing is stored.
+func CompareOffers(offers []Offer) (OfferComparison, error) {
+	var comparison OfferComparison
+	if len(offers) < MinOffers || len(offers) > MaxOffers {
+		return comparison, fmt.Errorf("%w: compare from %d to %d offers, got %d", ErrInvalidLoan, MinOffers, MaxOffers, len(offers))
+	}
+
+	for i, offer := range offers {
+		loan, err := offer.Loan()
+		if err != nil {
+			return comparison, err
+		}
+
+		cost, err := CalculateCost(loan)
+		if err != nil {
+			return comparison, fmt.Errorf("offer %q: %w", offer.Name, err)
+		}
+		comparison.Offers = append(comparison.Offers, cost)
+
+		cheapest := comparison.Offers[comparison.Cheapest]
+		if cmp.Or(cmp.Compare(cost.TotalCost, cheapest.TotalCost), cmp.Compare(cost.APR, cheapest.APR)) < 0 {
+			comparison.Cheapest = i
+		}
+	}
+
+	return comparison, nil
+}
@@ -0,0 +1,831 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// OutputFormat is the format used to write loans and payments.
+type OutputFormat string
+
+const (
+	FormatTable    OutputFormat = "table"
+	FormatJSON     OutputFormat = "json"
+	FormatCSV      OutputFormat = "csv"
+	FormatMarkdown OutputFormat = "markdown"
+)
+
+// currency is the symbol printed next to the amounts in the tables.
+var currency = "€"
+
+// SetCurrency changes the currency symbol printed next to the amounts.
+func SetCurrency(symbol string) {
+	currency = symbol
+}
+
+// formatMoney formats an amount followed by the currency symbol.
+func formatMoney(amount domain.Money) string {
+	return amount.String() + " " + currency
+}
+
+// ParseOutputFormat validates an output format name.
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch format := OutputFormat(name); format {
+	case FormatTable, FormatJSON, FormatCSV, FormatMarkdown:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown output format %q, use table, json, csv or markdown", name)
+}
+
+// Structure written by the json format of the payment history
+type paymentHistory struct {
+	LoanID          string              `json:"loan_id"`
+	LoanName        string              `json:"loan_name"`
+	Ledger          []domain.LedgerLine `json:"ledger"` // Entries of the ledger replayed, with the interest posted before each payment
+	TotalPaid       domain.Money        `json:"total_paid"`
+	InterestPaid    domain.Money        `json:"interest_paid"`
+	RemainingAmount domain.Money        `json:"remaining_amount"`
+	Credit          domain.Money        `json:"credit"`
+	Charges         domain.Money        `json:"charges"` // Late fees and penalty interest added to the balance
+}
+
+var loanHeader = []string{
+	"Loan Name",
+	"Loan ID",
+	"Status",
+	"Amount",
+	"Remaining Amount",
+	"Total Paid",
+	"Credit",
+	"Interest Rate",
+	"APR",
+	"Monthly Payment",
+	"Months to Pay Off",
+	"Years to Pay Off",
+}
+
+var paymentHeader = []string{"ID", "Date", "Type", "Description", "Amount", "Interest", "Principal", "Balance"}
+
+// WriteLoans writes the loans to w in the given format.
+func WriteLoans(w io.Writer, loans []domain.Loan, format OutputFormat) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, loans)
+	case FormatCSV:
+		rows := make([][]string, 0, len(loans))
+		for _, loan := range loans {
+			rows = append(rows, loanRow(loan))
+		}
+		return writeCSV(w, loanHeader, rows)
+	case FormatMarkdown:
+		table := newMarkdownTable(w, loanHeader)
+		for _, loan := range loans {
+			table.Append(loanRow(loan))
+		}
+		table.Render()
+		return nil
+	case FormatTable:
+		writeLoansTable(w, loans)
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// WritePaymentHistory writes the payment history of a loan to w in the given format.
+// The history is the ledger of the loan replayed, so every entry shows the balance it left.
+func WritePaymentHistory(w io.Writer, loan domain.Loan, format OutputFormat) error {
+	statement := loan.Replay()
+
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, paymentHistory{
+			LoanID:          loan.LoanID,
+			LoanName:        loan.LoanName,
+			Ledger:          statement.Lines,
+			TotalPaid:       statement.TotalPaid,
+			InterestPaid:    statement.InterestPaid,
+			RemainingAmount: statement.Balance,
+			Credit:          statement.Credit,
+			Charges:         loan.TotalCharges(),
+		})
+	case FormatCSV:
+		rows := make([][]string, 0, len(statement.Lines))
+		for _, line := range statement.Lines {
+			rows = append(rows, paymentRow(line, false))
+		}
+		return writeCSV(w, paymentHeader, rows)
+	case FormatMarkdown:
+		fmt.Fprintf(w, "### Payment history for Loan: %s (%s)\n\n", loan.LoanName, loan.LoanID)
+		table := newMarkdownTable(w, paymentHeader)
+		for _, line := range statement.Lines {
+			table.Append(paymentRow(line, true))
+		}
+		table.Append([]string{"", "", "", "**Total Paid**", formatMoney(statement.TotalPaid), formatMoney(statement.InterestPaid),
+			formatMoney(statement.TotalPaid - statement.InterestPaid), ""})
+		table.Render()
+		if charges := loan.TotalCharges(); charges > 0 {
+			fmt.Fprintf(w, "\nLate charges: %s\n", formatMoney(charges))
+		}
+		fmt.Fprintf(w, "\nRemaining balance: %s\n", formatMoney(statement.Balance))
+		if statement.Credit > 0 {
+			fmt.Fprintf(w, "Credit to refund: %s\n", formatMoney(statement.Credit))
+		}
+		return nil
+	case FormatTable:
+		writePaymentHistoryTable(w, loan.LoanID, loan.LoanName, statement, loan.TotalCharges())
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+var simulationHeader = []string{"", "Baseline", "With Extra Payments", "Saved"}
+
+// WriteSimulation writes the comparison of a prepayment simulation to w in the given format.
+func WriteSimulation(w io.Writer, simulation Simulation, format OutputFormat) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, simulation)
+	case FormatCSV:
+		return writeCSV(w, simulationHeader, simulationRows(simulation, false))
+	case FormatMarkdown:
+		fmt.Fprintf(w, "### Extra payments simulation for Loan: %s (%s)\n\n", simulation.LoanName, simulation.LoanID)
+		table := newMarkdownTable(w, simulationHeader)
+		table.AppendBulk(simulationRows(simulation, true))
+		table.Render()
+		return nil
+	case FormatTable:
+		fmt.Fprintf(w, "Extra payments simulation for Loan: %s (%s)\n", simulation.LoanName, simulation.LoanID)
+		fmt.Fprintf(w, "Monthly extra: %s, lump sums: %d\n\n", formatMoney(simulation.Plan.MonthlyExtra), len(simulation.Plan.LumpSums))
+
+		table := tablewriter.NewWriter(w)
+		table.SetHeader(simulationHeader)
+		table.SetHeaderColor(
+			tablewriter.Colors{},
+			tablewriter.Colors{tablewriter.Bold, tablewriter.BgBlackColor},
+			tablewriter.Colors{tablewriter.Bold, tablewriter.BgGreenColor},
+			tablewriter.Colors{tablewriter.FgHiGreenColor, tablewriter.Bold})
+		table.SetAutoFormatHeaders(false)
+		table.SetAlignment(tablewriter.ALIGN_RIGHT)
+		table.AppendBulk(simulationRows(simulation, true))
+		table.Render()
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// simulationRows formats the payoff date, installments, interest and total paid of both schedules.
+func simulationRows(simulation Simulation, withCurrency bool) [][]string {
+	format := domain.Money.String
+	if withCurrency {
+		format = formatMoney
+	}
+
+	baseline, withPrepayment := simulation.Baseline, simulation.WithPrepayment
+	return [][]string{
+		{"Payoff Date", formatDate(simulation.BaselinePayoff), formatDate(simulation.NewPayoff), fmt.Sprintf("%d months", simulation.MonthsSaved)},
+		{"Installments", strconv.Itoa(len(baseline.Periods)), strconv.Itoa(len(withPrepayment.Periods)), strconv.Itoa(simulation.MonthsSaved)},
+		{"Total Interest", format(baseline.TotalInterest), format(withPrepayment.TotalInterest), format(simulation.InterestSaved)},
+		{"Total Paid", format(baseline.TotalPayment), format(withPrepayment.TotalPayment), format(baseline.TotalPayment - withPrepayment.TotalPayment)},
+	}
+}
+
+// formatDate formats a date of a schedule, the zero date means there is nothing to pay.
+func formatDate(date time.Time) string {
+	if date.IsZero() {
+		return "-"
+	}
+	return date.Format(time.DateOnly)
+}
+
+var rateChangeHeader = []string{"", "Before", "After", "Change"}
+
+// WriteRateChangeImpact writes how a change of the interest rate affects a loan to w in the given format.
+func WriteRateChangeImpact(w io.Writer, impact RateChangeImpact, format OutputFormat) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, impact)
+	case FormatCSV:
+		return writeCSV(w, rateChangeHeader, rateChangeRows(impact, false))
+	case FormatMarkdown:
+		fmt.Fprintf(w, "### Interest rate change for Loan: %s (%s) from %s\n\n", impact.LoanName, impact.LoanID, impact.Change.EffectiveDate)
+		table := newMarkdownTable(w, rateChangeHeader)
+		table.AppendBulk(rateChangeRows(impact, true))
+		table.Render()
+		return nil
+	case FormatTable:
+		fmt.Fprintf(w, "Interest rate change for Loan: %s (%s) from %s\n\n", impact.LoanName, impact.LoanID, impact.Change.EffectiveDate)
+
+		table := tablewriter.NewWriter(w)
+		table.SetHeader(rateChangeHeader)
+		table.SetHeaderColor(
+			tablewriter.Colors{},
+			tablewriter.Colors{tablewriter.Bold, tablewriter.BgBlackColor},
+			tablewriter.Colors{tablewriter.Bold, tablewriter.BgGreenColor},
+			tablewriter.Colors{tablewriter.FgHiYellowColor, tablewriter.Bold})
+		table.SetAutoFormatHeaders(false)
+		table.SetAlignment(tablewriter.ALIGN_RIGHT)
+		table.AppendBulk(rateChangeRows(impact, true))
+		table.Render()
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// rateChangeRows formats the rate, monthly payment, payoff date, installments and interest before
+// and after a rate change, with the difference signed.
+func rateChangeRows(impact RateChangeImpact, withCurrency bool) [][]string {
+	format := domain.Money.String
+	if withCurrency {
+		format = formatMoney
+	}
+	signed := func(amount domain.Money) string {
+		if amount > 0 {
+			return "+" + format(amount)
+		}
+		return format(amount)
+	}
+
+	before, after := impact.Before, impact.After
+	months := len(after.Periods) - len(before.Periods)
+	return [][]string{
+		{"Interest Rate", fmt.Sprintf("%.2f", impact.RateBefore), fmt.Sprintf("%.2f", impact.Change.Rate), fmt.Sprintf("%+.2f", impact.Change.Rate-impact.RateBefore)},
+		{"Monthly Payment", format(impact.PaymentBefore), format(impact.PaymentAfter), signed(impact.PaymentAfter - impact.PaymentBefore)},
+		{"Payoff Date", formatDate(impact.PayoffBefore), formatDate(impact.PayoffAfter), fmt.Sprintf("%+d months", months)},
+		{"Installments", strconv.Itoa(len(before.Periods)), strconv.Itoa(len(after.Periods)), fmt.Sprintf("%+d", months)},
+		{"Total Interest", format(before.TotalInterest), format(after.TotalInterest), signed(after.TotalInterest - before.TotalInterest)},
+		{"Total Paid", format(before.TotalPayment), format(after.TotalPayment), signed(after.TotalPayment - before.TotalPayment)},
+	}
+}
+
+// WritePayoffPlans writes the payoff plans side by side, followed by the month by month
+// payments of each plan, to w in the given format. The csv format only has the monthly payments.
+func WritePayoffPlans(w io.Writer, plans []PayoffPlan, format OutputFormat) error {
+	if format == FormatJSON {
+		return writeJSON(w, plans)
+	}
+	if len(plans) == 0 {
+		return nil
+	}
+
+	// Every plan has the same loans in the same order
+	loans := plans[0].Loans
+	monthHeader := []string{"Month", "Date"}
+	for _, loan := range loans {
+		monthHeader = append(monthHeader, loan.LoanName)
+	}
+	monthHeader = append(monthHeader, "Remaining Balance")
+
+	switch format {
+	case FormatCSV:
+		var rows [][]string
+		for _, plan := range plans {
+			for _, row := range planMonthRows(plan, false) {
+				rows = append(rows, append([]string{string(plan.Strategy)}, row...))
+			}
+		}
+		return writeCSV(w, append([]string{"Strategy"}, monthHeader...), rows)
+	case FormatMarkdown:
+		fmt.Fprintf(w, "### Payoff plans with a monthly budget of %s\n\n", formatMoney(plans[0].Budget))
+		table := newMarkdownTable(w, planComparisonHeader(plans))
+		table.AppendBulk(planComparisonRows(plans))
+		table.Render()
+
+		for _, plan := range plans {
+			fmt.Fprintf(w, "\n#### %s\n\n", plan.Strategy)
+			table := newMarkdownTable(w, monthHeader)
+			table.AppendBulk(planMonthRows(plan, true))
+			table.Render()
+		}
+		return nil
+	case FormatTable:
+		fmt.Fprintf(w, "Payoff plans with a monthly budget of %s\n\n", formatMoney(plans[0].Budget))
+		header := planComparisonHeader(plans)
+		table := tablewriter.NewWriter(w)
+		table.SetHeader(header)
+		table.SetHeaderColor(headerColors(len(header), tablewriter.Colors{tablewriter.Bold, tablewriter.BgGreenColor})...)
+		table.SetAutoFormatHeaders(false)
+		table.SetAlignment(tablewriter.ALIGN_RIGHT)
+		table.AppendBulk(planComparisonRows(plans))
+		table.Render()
+
+		for _, plan := range plans {
+			fmt.Fprintf(w, "\nMonth by month plan: %s\n", plan.Strategy)
+			table := tablewriter.NewWriter(w)
+			table.SetHeader(monthHeader)
+			table.SetHeaderColor(headerColors(len(monthHeader), tablewriter.Colors{tablewriter.Bold, tablewriter.BgBlackColor})...)
+			table.SetAutoFormatHeaders(false)
+			table.SetAlignment(tablewriter.ALIGN_RIGHT)
+			table.AppendBulk(planMonthRows(plan, true))
+			table.Render()
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// planComparisonHeader returns a column for each strategy.
+func planComparisonHeader(plans []PayoffPlan) []string {
+	header := []string{""}
+	for _, plan := range plans {
+		header = append(header, string(plan.Strategy))
+	}
+	return header
+}
+
+// planComparisonRows formats the payoff date of each loan and the totals of every plan.
+func planComparisonRows(plans []PayoffPlan) [][]string {
+	var rows [][]string
+	for i, loan := range plans[0].Loans {
+		row := []string{fmt.Sprintf("%s (%s) paid off", loan.LoanName, loan.LoanID)}
+		for _, plan := range plans {
+			row = append(row, formatDate(plan.Loans[i].PayoffDate))
+		}
+		rows = append(rows, row)
+	}
+
+	debtFree := []string{"Debt Free"}
+	months := []string{"Months"}
+	interest := []string{"Total Interest"}
+	paid := []string{"Total Paid"}
+	order := []string{"Order"}
+	for _, plan := range plans {
+		debtFree = append(debtFree, formatDate(plan.PayoffDate))
+		months = append(months, strconv.Itoa(len(plan.Months)))
+		interest = append(interest, formatMoney(plan.TotalInterest))
+		paid = append(paid, formatMoney(plan.TotalPaid))
+		order = append(order, strings.Join(plan.Order, ", "))
+	}
+	return append(rows, debtFree, months, interest, paid, order)
+}
+
+// planMonthRows formats the payment of every loan in each month of the plan.
+func planMonthRows(plan PayoffPlan, withCurrency bool) [][]string {
+	format := domain.Money.String
+	if withCurrency {
+		format = formatMoney
+	}
+
+	rows := make([][]string, 0, len(plan.Months))
+	for _, month := range plan.Months {
+		row := []string{strconv.Itoa(month.Number), month.Date.Format(time.DateOnly)}
+		for _, payment := range month.Payments {
+			row = append(row, format(payment))
+		}
+		rows = append(rows, append(row, format(month.Balance)))
+	}
+	return rows
+}
+
+// headerColors returns the same color for the given number of header columns.
+func headerColors(columns int, color tablewriter.Colors) []tablewriter.Colors {
+	colors := make([]tablewriter.Colors, columns)
+	for i := range colors {
+		colors[i] = color
+	}
+	return colors
+}
+
+// formatAPR formats the APR of the loan, or a dash when its terms do not pay it off.
+func formatAPR(loan domain.Loan) string {
+	apr, err := loan.APR()
+	if err != nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", apr)
+}
+
+var loanCostHeader = []string{
+	"Loan Name",
+	"Amount",
+	"Interest Rate",
+	"Effective Rate",
+	"APR",
+	"Monthly Payment",
+	"Monthly Fees",
+	"Installments",
+	"Payoff Date",
+	"Total Interest",
+	"Upfront Fees",
+	"Total Fees",
+	"Total Cost",
+	"Total Paid",
+}
+
+// WriteLoanCosts writes the cost of the loans side by side, a column for each loan, to w in the
+// given format. The csv format has a row for each loan instead.
+func WriteLoanCosts(w io.Writer, costs []LoanCost, format OutputFormat) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, costs)
+	case FormatCSV:
+		rows := make([][]string, 0, len(costs))
+		for _, cost := range costs {
+			rows = append(rows, loanCostRow(cost, false))
+		}
+		return writeCSV(w, loanCostHeader, rows)
+	case FormatMarkdown:
+		header, rows := loanCostColumns(costs)
+		table := newMarkdownTable(w, header)
+		table.AppendBulk(rows)
+		table.Render()
+		return nil
+	case FormatTable:
+		header, rows := loanCostColumns(costs)
+		table := tablewriter.NewWriter(w)
+		table.SetHeader(header)
+		table.SetHeaderColor(headerColors(len(header), tablewriter.Colors{tablewriter.Bold, tablewriter.BgGreenColor})...)
+		table.SetAutoFormatHeaders(false)
+		table.SetAlignment(tablewriter.ALIGN_RIGHT)
+		table.AppendBulk(rows)
+		table.Render()
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// WriteOfferComparison writes the cost of the offers side by side to w in the given format,
+// highlighting the cheapest one. The csv format has a row for each offer instead.
+func WriteOfferComparison(w io.Writer, comparison OfferComparison, format OutputFormat) error {
+	costs := comparison.Offers
+	cheapest := comparison.Cheapest
+
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, comparison)
+	case FormatCSV:
+		rows := make([][]string, 0, len(costs))
+		for i, cost := range costs {
+			rows = append(rows, append(loanCostRow(cost, false), strconv.FormatBool(i == cheapest)))
+		}
+		return writeCSV(w, append(slices.Clone(loanCostHeader), "Cheapest"), rows)
+	case FormatMarkdown:
+		header, rows := loanCostColumns(costs)
+		header[cheapest+1] = "**" + header[cheapest+1] + " (cheapest)**"
+		table := newMarkdownTable(w, header)
+		table.AppendBulk(rows)
+		table.Render()
+		return nil
+	case FormatTable:
+		header, rows := loanCostColumns(costs)
+		header[cheapest+1] += " (cheapest)"
+
+		headerColors := headerColors(len(header), tablewriter.Colors{tablewriter.Bold, tablewriter.BgBlackColor})
+		headerColors[cheapest+1] = tablewriter.Colors{tablewriter.Bold, tablewriter.BgGreenColor}
+		columnColors := make([]tablewriter.Colors, len(header))
+		columnColors[cheapest+1] = tablewriter.Colors{tablewriter.FgHiGreenColor, tablewriter.Bold}
+
+		table := tablewriter.NewWriter(w)
+		table.SetHeader(header)
+		table.SetHeaderColor(headerColors...)
+		table.SetColumnColor(columnColors...)
+		table.SetAutoFormatHeaders(false)
+		table.SetAlignment(tablewriter.ALIGN_RIGHT)
+		table.AppendBulk(rows)
+		table.Render()
+
+		fmt.Fprintf(w, "\nThe cheapest offer is %s, with a total cost of %s and an APR of %.2f%%\n",
+			costs[cheapest].LoanName, formatMoney(costs[cheapest].TotalCost), costs[cheapest].APR)
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// loanCostRow formats the cost of a loan in the order of loanCostHeader.
+func loanCostRow(cost LoanCost, withCurrency bool) []string {
+	format := domain.Money.String
+	if withCurrency {
+		format = formatMoney
+	}
+
+	return []string{
+		cost.LoanName,
+		format(cost.Amount),
+		fmt.Sprintf("%.2f", cost.Interest),
+		fmt.Sprintf("%.2f", cost.EffectiveRate),
+		fmt.Sprintf("%.2f", cost.APR),
+		format(cost.MonthlyPayment),
+		format(cost.MonthlyFees),
+		strconv.Itoa(cost.Installments),
+		formatDate(cost.PayoffDate),
+		format(cost.TotalInterest),
+		format(cost.UpfrontFees),
+		format(cost.TotalFees),
+		format(cost.TotalCost),
+		format(cost.TotalPaid),
+	}
+}
+
+// loanCostColumns turns the cost rows into columns, with the loan names as header.
+func loanCostColumns(costs []LoanCost) ([]string, [][]string) {
+	header := []string{""}
+	rows := make([][]string, len(loanCostHeader)-1)
+	for i := range rows {
+		rows[i] = []string{loanCostHeader[i+1]}
+	}
+
+	for _, cost := range costs {
+		row := loanCostRow(cost, true)
+		header = append(header, row[0])
+		for i := range rows {
+			rows[i] = append(rows[i], row[i+1])
+		}
+	}
+	return header, rows
+}
+
+var dueStatusHeader = []string{
+	"Loan Name",
+	"Loan ID",
+	"Next Due Date",
+	"Next Due Amount",
+	"Installments Paid",
+	"Overdue Installments",
+	"Overdue Since",
+	"Arrears",
+	"Late Charges",
+	"Maturity Date",
+}
+
+// WriteDueStatuses writes where the loans stand against their payment calendar to w in the given format.
+func WriteDueStatuses(w io.Writer, statuses []DueStatus, format OutputFormat) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, statuses)
+	case FormatCSV:
+		rows := make([][]string, 0, len(statuses))
+		for _, status := range statuses {
+			rows = append(rows, dueStatusRow(status, false))
+		}
+		return writeCSV(w, dueStatusHeader, rows)
+	case FormatMarkdown:
+		table := newMarkdownTable(w, dueStatusHeader)
+		for _, status := range statuses {
+			table.Append(dueStatusRow(status, true))
+		}
+		table.Render()
+		return nil
+	case FormatTable:
+		if len(statuses) == 0 {
+			fmt.Fprintln(w, "No active loans with a payment calendar.")
+			return nil
+		}
+
+		table := tablewriter.NewWriter(w)
+		table.SetHeader(dueStatusHeader)
+		table.SetHeaderColor(headerColors(len(dueStatusHeader), tablewriter.Colors{tablewriter.Bold, tablewriter.BgGreenColor})...)
+		table.SetAutoFormatHeaders(false)
+		for _, status := range statuses {
+			row := dueStatusRow(status, true)
+			if status.IsOverdue() {
+				table.Rich(row, headerColors(len(row), tablewriter.Colors{tablewriter.FgHiRedColor, tablewriter.Bold}))
+			} else {
+				table.Append(row)
+			}
+		}
+		table.Render()
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// WriteDueBanner writes a line for each overdue loan and each installment due in the next days.
+// It writes nothing when there are no reminders.
+func WriteDueBanner(w io.Writer, reminders []DueStatus) {
+	if len(reminders) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "======= Upcoming and overdue =======")
+	for _, status := range reminders {
+		if status.IsOverdue() {
+			fmt.Fprintf(w, "! %s (%s): %d installment(s) overdue since %s, %s in arrears", status.LoanName, status.LoanID,
+				status.OverdueInstallments, formatDate(status.OverdueSince), formatMoney(status.Arrears))
+			if status.Charges > 0 {
+				fmt.Fprintf(w, ", %s in late charges", formatMoney(status.Charges))
+			}
+			fmt.Fprintln(w)
+		}
+		if status.IsUpcoming(UpcomingDays) {
+			fmt.Fprintf(w, "- %s (%s): %s due on %s\n", status.LoanName, status.LoanID,
+				formatMoney(status.NextDueAmount), formatDate(status.NextDueDate))
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// dueStatusRow formats the due status of a loan in the order of dueStatusHeader.
+func dueStatusRow(status DueStatus, withCurrency bool) []string {
+	format := domain.Money.String
+	if withCurrency {
+		format = formatMoney
+	}
+
+	return []string{
+		status.LoanName,
+		status.LoanID,
+		formatDate(status.NextDueDate),
+		format(status.NextDueAmount),
+		fmt.Sprintf("%d/%d", status.InstallmentsPaid, status.InstallmentsDue),
+		strconv.Itoa(status.OverdueInstallments),
+		formatDate(status.OverdueSince),
+		format(status.Arrears),
+		format(status.Charges),
+		formatDate(status.MaturityDate),
+	}
+}
+
+func loanRow(loan domain.Loan) []string {
+	statement := loan.Replay()
+	return []string{
+		loan.LoanName,
+		loan.LoanID,
+		string(loan.GetStatus()),
+		loan.Amount.String(),
+		statement.Balance.String(),
+		statement.TotalPaid.String(),
+		statement.Credit.String(),
+		fmt.Sprintf("%.2f", loan.CurrentRate()),
+		formatAPR(loan),
+		loan.CurrentMonthlyPayment().String(),
+		fmt.Sprintf("%.2f", loan.TimePaidOff),
+		fmt.Sprintf("%.2f", loan.TimePaidOff/12),
+	}
+}
+
+// paymentRow formats an entry of the ledger, the currency is left out of the machine-readable
+// formats. Only the payments are split between interest and principal.
+func paymentRow(line domain.LedgerLine, withCurrency bool) []string {
+	format := domain.Money.String
+	if withCurrency {
+		format = formatMoney
+	}
+
+	interest, principal := "", ""
+	if line.Type == domain.TransactionPayment {
+		interest, principal = format(line.Interest), format(line.Principal)
+	}
+
+	amount := ""
+	if line.Type != domain.TransactionRateChange {
+		amount = format(line.Amount)
+	}
+
+	return []string{
+		line.ID,
+		line.DateTime,
+		string(line.Type),
+		line.Description,
+		amount,
+		interest,
+		principal,
+		format(line.Balance),
+	}
+}
+
+func writeJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// newMarkdownTable creates a table writer that renders GitHub flavored markdown.
+func newMarkdownTable(w io.Writer, header []string) *tablewriter.Table {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(header)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoWrapText(false)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	return table
+}
+
+// writeLoansTable writes the colored loans table.
+func writeLoansTable(w io.Writer, loans []domain.Loan) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(loanHeader)
+	for _, loan := range loans {
+
+		table.Append(loanRow(loan))
+
+		table.SetHeaderColor(
+			tablewriter.Colors{tablewriter.Bold, tablewriter.BgGreenColor},
+			tablewriter.Colors{tablewriter.FgHiRedColor, tablewriter.Bold, tablewriter.BgBlackColor},
+			tablewriter.Colors{tablewriter.BgCyanColor, tablewriter.FgWhiteColor},
+			tablewriter.Colors{},
+			tablewriter.Colors{},
+			tablewriter.Colors{},
+			tablewriter.Colors{},
+			tablewriter.Colors{},
+			tablewriter.Colors{},
+			tablewriter.Colors{},
+			tablewriter.Colors{},
+			tablewriter.Colors{},
+		)
+
+	}
+
+	table.SetAutoFormatHeaders(true)
+	// table.SetBorder(false)
+	table.Render()
+}
+
+// writePaymentHistoryTable writes the colored payment history table followed by the totals.
+func writePaymentHistoryTable(w io.Writer, loanID, loanName string, statement domain.Statement, charges domain.Money) {
+	if len(statement.Lines) == 0 {
+		fmt.Fprintln(w, "No payment history found for this loan.")
+		return
+	}
+
+	fmt.Fprintf(w, "Payment history for Loan: %s (%s)\n", loanName, loanID)
+	fmt.Fprintln(w)
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(paymentHeader)
+
+	table.SetHeaderColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.BgGreenColor},
+		tablewriter.Colors{tablewriter.FgHiRedColor, tablewriter.Bold, tablewriter.BgBlackColor},
+		tablewriter.Colors{},
+		tablewriter.Colors{tablewriter.BgCyanColor, tablewriter.FgWhiteColor},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{})
+
+	for _, line := range statement.Lines {
+		// The charges of late installments stand out from the payments
+		if line.IsCharge() {
+			table.Rich(paymentRow(line, true), []tablewriter.Colors{{}, {}, {tablewriter.FgHiRedColor}, {}, {tablewriter.FgHiRedColor}, {}, {}, {}})
+			continue
+		}
+		table.Append(paymentRow(line, true))
+	}
+
+	table.SetAutoFormatHeaders(true)
+	table.SetFooter([]string{
+		"",
+		"",
+		"",
+		"Total Paid",
+		formatMoney(statement.TotalPaid),
+		formatMoney(statement.InterestPaid),
+		formatMoney(statement.TotalPaid - statement.InterestPaid),
+		"",
+	})
+	table.SetFooterColor(
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{tablewriter.Bold},
+		tablewriter.Colors{tablewriter.FgHiRedColor},
+		tablewriter.Colors{tablewriter.FgHiRedColor},
+		tablewriter.Colors{tablewriter.FgHiRedColor},
+		tablewriter.Colors{})
+
+	// table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	table.SetBorder(false)
+
+	table.Render()
+
+	totalTable := tablewriter.NewWriter(w)
+	header := []string{"Total Paid"}
+	totals := []string{formatMoney(statement.TotalPaid)}
+	if charges > 0 {
+		header = append(header, "Late Charges")
+		totals = append(totals, formatMoney(charges))
+	}
+	header = append(header, "Remaining Balance")
+	totals = append(totals, formatMoney(statement.Balance))
+	if statement.Credit > 0 {
+		header = append(header, "Credit to Refund")
+		totals = append(totals, formatMoney(statement.Credit))
+	}
+	totalTable.SetHeader(header)
+	totalTable.Append(totals)
+	totalTable.SetAutoFormatHeaders(true)
+	totalTable.SetAlignment(tablewriter.ALIGN_RIGHT)
+	totalTable.Render()
+
+	fmt.Fprintln(w)
+}
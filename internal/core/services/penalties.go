@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SetPenaltyRule sets the late fee and penalty interest of a loan, nil removes them, and posts
+// or reverses the charges of its late installments.
+func (s *UserService) SetPenaltyRule(userName string, loanID string, rule *domain.PenaltyRule) error {
+	selectedLoan, err := s.lookupLoan(userName, loanID)
+	if err != nil {
+		return err
+	}
+
+	if status := selectedLoan.GetStatus(); !status.IsOpen() {
+		return fmt.Errorf("%w: loan %s is %s, reopen it to change its penalties", ErrLoanNotOpen, loanID, status)
+	}
+
+	if err := selectedLoan.SetPenaltyRule(rule); err != nil {
+		return err
+	}
+
+	applyPenalties(selectedLoan, time.Now())
+	return nil
+}
+
+// ApplyPenalties brings the charges of the late installments of every loan of the user up to date
+// on the given date. It returns how many charges were posted and reversed.
+func (s *UserService) ApplyPenalties(userName string, date time.Time) (posted, reversed int, err error) {
+	user, err := s.lookupUser(userName)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := range user.Loans {
+		loanPosted, loanReversed := applyPenalties(&user.Loans[i], date)
+		posted += loanPosted
+		reversed += loanReversed
+	}
+	return posted, reversed, nil
+}
+
+// UserWithPenalties returns a copy of the user with the charges of the late installments of every
+// loan up to date on the given date. The views that only read the data show the copy, so the
+// charges are only posted to the stored data by the changes of each loan.
+func (s *UserService) UserWithPenalties(userName string, date time.Time) (*domain.User, error) {
+	user, err := s.lookupUser(userName)
+	if err != nil {
+		return nil, err
+	}
+
+	view := user.Clone()
+	for i := range view.Loans {
+		applyPenalties(&view.Loans[i], date)
+	}
+	return &view, nil
+}
+
+// applyPenalties brings the charges of the late installments of the loan up to date. A loan whose
+// calendar cannot be computed is logged and left as it is.
+func applyPenalties(loan *domain.Loan, date time.Time) (posted, reversed int) {
+	posted, reversed, err := loan.ApplyPenalties(date)
+	if err != nil {
+		log.Error().Err(err).Str("loan_id", loan.LoanID).Msg("Could not apply the penalties of the loan.")
+	}
+	return posted, reversed
+}
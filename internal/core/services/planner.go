@@ -0,0 +1,238 @@
+package services
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+)
+
+// maxPlanMonths caps the length of a payoff plan (100 years of installments).
+const maxPlanMonths = 1200
+
+// Strategy is the order in which a payoff plan puts the money left over after the monthly payments.
+type Strategy string
+
+const (
+	StrategySnowball  Strategy = "snowball"  // Smallest balance first
+	StrategyAvalanche Strategy = "avalanche" // Highest interest rate first
+	StrategyCustom    Strategy = "custom"    // Order given by the user
+)
+
+// ParseStrategy validates a payoff strategy name.
+func ParseStrategy(name string) (Strategy, error) {
+	switch strategy := Strategy(name); strategy {
+	case StrategySnowball, StrategyAvalanche, StrategyCustom:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown payoff strategy %q, use snowball, avalanche or custom", name)
+}
+
+// Structure to represent the payoff of one loan in a plan
+type PlannedLoan struct {
+	LoanID         string       `json:"loan_id"`
+	LoanName       string       `json:"loan_name"`
+	Balance        domain.Money `json:"balance"`         // Balance when the plan starts
+	Interest       float64      `json:"interest"`        // Rate when the plan starts
+	MonthlyPayment domain.Money `json:"monthly_payment"` // Monthly payment when the plan starts
+	PayoffDate     time.Time    `json:"payoff_date"`
+	Months         int          `json:"months"` // Installments until the loan is paid off
+	TotalInterest  domain.Money `json:"total_interest"`
+	TotalPaid      domain.Money `json:"total_paid"`
+}
+
+// Structure to represent one month of a payoff plan
+type PlanMonth struct {
+	Number   int            `json:"number"`
+	Date     time.Time      `json:"date"`
+	Payments []domain.Money `json:"payments"` // Payment of each loan, in the order of PayoffPlan.Loans
+	Balance  domain.Money   `json:"balance"`  // Sum of the balances left after the payments
+}
+
+// Structure to represent how a monthly budget pays off a set of loans with a strategy
+type PayoffPlan struct {
+	Strategy      Strategy      `json:"strategy"`
+	Budget        domain.Money  `json:"budget"`
+	Order         []string      `json:"order"` // IDs of the loans in the order they get the money left over
+	Loans         []PlannedLoan `json:"loans"` // In the order the loans were given
+	Months        []PlanMonth   `json:"months"`
+	PayoffDate    time.Time     `json:"payoff_date"` // When the last loan is paid off
+	TotalInterest domain.Money  `json:"total_interest"`
+	TotalPaid     domain.Money  `json:"total_paid"`
+}
+
+// PlanStrategies builds the snowball and avalanche plans of the active loans with the given monthly
+// budget, and the custom plan too when an order of loan IDs is given. Every loan gets its monthly
+// payment, the rest of the budget goes to the loans in the order of the strategy, and the payment
+// of a loan once it is paid off rolls into the next one. The loans are not changed.
+func PlanStrategies(loans []domain.Loan, budget domain.Money, order []string, from time.Time) ([]PayoffPlan, error) {
+	strategies := []Strategy{StrategySnowball, StrategyAvalanche}
+	if len(order) > 0 {
+		strategies = append(strategies, StrategyCustom)
+	}
+
+	plans := make([]PayoffPlan, 0, len(strategies))
+	for _, strategy := range strategies {
+		plan, err := PlanPayoff(loans, budget, strategy, order, from)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// PlanPayoff builds the month by month plan to pay off the active loans with the given monthly
+// budget, starting one month after the given date. The order is only used by the custom strategy,
+// loans missing from it are paid after the listed ones.
+func PlanPayoff(loans []domain.Loan, budget domain.Money, strategy Strategy, order []string, from time.Time) (PayoffPlan, error) {
+	plan := PayoffPlan{Strategy: strategy, Budget: budget}
+
+	var minimum domain.Money
+	var balances []domain.Money
+	var active []domain.Loan
+	for _, loan := range loans {
+		balance := loan.OutstandingBalance()
+		if loan.GetStatus() != domain.StatusActive || balance <= 0 {
+			continue
+		}
+
+		plan.Loans = append(plan.Loans, PlannedLoan{
+			LoanID:         loan.LoanID,
+			LoanName:       loan.LoanName,
+			Balance:        balance,
+			Interest:       loan.RateAt(from),
+			MonthlyPayment: loan.MonthlyPaymentAt(from),
+		})
+		active = append(active, loan)
+		balances = append(balances, balance)
+		minimum += loan.MonthlyPaymentAt(from)
+	}
+
+	if len(plan.Loans) == 0 {
+		return plan, fmt.Errorf("%w: there are no active loans with a balance to pay off", ErrLoanNotFound)
+	}
+	if budget < minimum {
+		return plan, fmt.Errorf("%w: the budget of %s does not cover the %s of monthly payments", ErrInvalidAmount, budget, minimum)
+	}
+
+	priority, err := planOrder(plan.Loans, strategy, order)
+	if err != nil {
+		return plan, err
+	}
+	for _, i := range priority {
+		plan.Order = append(plan.Order, plan.Loans[i].LoanID)
+	}
+
+	for n := 1; slices.ContainsFunc(balances, func(b domain.Money) bool { return b > 0 }); n++ {
+		if n > maxPlanMonths {
+			return plan, fmt.Errorf("%w: the budget does not pay off the loans in %d years", ErrPaymentTooLow, maxPlanMonths/12)
+		}
+
+		month := PlanMonth{Number: n, Date: from.AddDate(0, n, 0), Payments: make([]domain.Money, len(plan.Loans))}
+		monthStart := from.AddDate(0, n-1, 0)
+		owed := make([]domain.Money, len(plan.Loans))
+		left := budget
+
+		// Every loan gets its monthly payment first, or what is left to pay it off. Variable
+		// rate loans use the rate and monthly payment in effect when the month starts.
+		for i, balance := range balances {
+			if balance <= 0 {
+				continue
+			}
+			interest := domain.MonthlyInterest(balance, active[i].RateAt(monthStart))
+			plan.Loans[i].TotalInterest += interest
+			owed[i] = balance + interest
+
+			month.Payments[i] = min(active[i].MonthlyPaymentAt(monthStart), owed[i])
+			left -= month.Payments[i]
+		}
+
+		// The rest of the budget goes to the loans in the order of the strategy
+		for _, i := range priority {
+			if left <= 0 {
+				break
+			}
+			extra := min(left, owed[i]-month.Payments[i])
+			month.Payments[i] += extra
+			left -= extra
+		}
+
+		for i := range balances {
+			if owed[i] == 0 {
+				continue
+			}
+			balances[i] = owed[i] - month.Payments[i]
+			month.Balance += balances[i]
+
+			planned := &plan.Loans[i]
+			planned.TotalPaid += month.Payments[i]
+			if balances[i] <= 0 {
+				planned.PayoffDate = month.Date
+				planned.Months = n
+			}
+		}
+
+		plan.Months = append(plan.Months, month)
+		plan.PayoffDate = month.Date
+	}
+
+	for _, planned := range plan.Loans {
+		plan.TotalInterest += planned.TotalInterest
+		plan.TotalPaid += planned.TotalPaid
+	}
+
+	return plan, nil
+}
+
+// planOrder returns the indexes of the loans in the order they get the money left over.
+func planOrder(loans []PlannedLoan, strategy Strategy, order []string) ([]int, error) {
+	priority := make([]int, len(loans))
+	for i := range priority {
+		priority[i] = i
+	}
+
+	bySmallestBalance := func(a, b int) int {
+		return cmp.Or(cmp.Compare(loans[a].Balance, loans[b].Balance), cmp.Compare(loans[b].Interest, loans[a].Interest))
+	}
+
+	switch strategy {
+	case StrategySnowball:
+		slices.SortStableFunc(priority, bySmallestBalance)
+	case StrategyAvalanche:
+		slices.SortStableFunc(priority, func(a, b int) int {
+			return cmp.Or(cmp.Compare(loans[b].Interest, loans[a].Interest), cmp.Compare(loans[a].Balance, loans[b].Balance))
+		})
+	case StrategyCustom:
+		position := make(map[string]int, len(order))
+		for i, loanID := range order {
+			if !slices.ContainsFunc(loans, func(loan PlannedLoan) bool { return loan.LoanID == loanID }) {
+				return nil, fmt.Errorf("%w: %q is not an active loan with a balance", ErrLoanNotFound, loanID)
+			}
+			if _, ok := position[loanID]; !ok {
+				position[loanID] = i
+			}
+		}
+
+		// The loans missing from the order go last, smallest balance first
+		slices.SortStableFunc(priority, func(a, b int) int {
+			positionA, listedA := position[loans[a].LoanID]
+			positionB, listedB := position[loans[b].LoanID]
+			switch {
+			case listedA && listedB:
+				return cmp.Compare(positionA, positionB)
+			case listedA:
+				return -1
+			case listedB:
+				return 1
+			}
+			return bySmallestBalance(a, b)
+		})
+	default:
+		return nil, fmt.Errorf("unknown payoff strategy %q", strategy)
+	}
+
+	return priority, nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+)
+
+// Structure to represent how a change of the interest rate affects the rest of a loan
+type RateChangeImpact struct {
+	LoanID        string            `json:"loan_id"`
+	LoanName      string            `json:"loan_name"`
+	Change        domain.RateChange `json:"change"`
+	RateBefore    float64           `json:"rate_before"`
+	PaymentBefore domain.Money      `json:"payment_before"` // Monthly payment before the change
+	PaymentAfter  domain.Money      `json:"payment_after"`  // Monthly payment once the change applies
+	Before        domain.Schedule   `json:"before"`
+	After         domain.Schedule   `json:"after"`
+	PayoffBefore  time.Time         `json:"payoff_before"`
+	PayoffAfter   time.Time         `json:"payoff_after"`
+}
+
+// ChangeLoanRate records a change of the interest rate of a loan, effective on the given date, and
+// returns the schedules from today before and after the change.
+func (s *UserService) ChangeLoanRate(userName string, loanID string, change domain.RateChange) (RateChangeImpact, error) {
+	impact := RateChangeImpact{Change: change}
+
+	selectedLoan, err := s.lookupLoan(userName, loanID)
+	if err != nil {
+		return impact, err
+	}
+
+	if status := selectedLoan.GetStatus(); !status.IsOpen() {
+		return impact, fmt.Errorf("%w: loan %s is %s, reopen it to change its rate", ErrLoanNotOpen, loanID, status)
+	}
+
+	// Keep the loan as it was to compare it with the changed one
+	before := *selectedLoan
+	before.Ledger = slices.Clone(selectedLoan.Ledger)
+	before.RateChanges = slices.Clone(selectedLoan.RateChanges)
+
+	if err := selectedLoan.AddRateChange(change); err != nil {
+		return impact, err
+	}
+
+	now := time.Now()
+	effective, err := time.Parse(time.RFC3339, change.EffectiveDate)
+	if err != nil || effective.Before(now) {
+		effective = now
+	}
+
+	impact.LoanID = selectedLoan.LoanID
+	impact.LoanName = selectedLoan.LoanName
+	impact.RateBefore = before.RateAt(effective)
+	impact.PaymentBefore = before.MonthlyPaymentAt(effective)
+	impact.PaymentAfter = selectedLoan.MonthlyPaymentAt(effective)
+
+	// The schedules only fail when the monthly payment does not cover the interest, which
+	// the new rate was checked for
+	impact.Before, _ = before.AmortizationSchedule(now)
+	impact.After, _ = selectedLoan.AmortizationSchedule(now)
+	impact.PayoffBefore = payoffDate(impact.Before)
+	impact.PayoffAfter = payoffDate(impact.After)
+
+	return impact, nil
+}
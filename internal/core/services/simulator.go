@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+)
+
+// Structure to represent hypothetical extra payments of principal
+type PrepaymentPlan struct {
+	MonthlyExtra domain.Money        `json:"monthly_extra"` // Paid on top of every installment
+	LumpSums     []domain.Prepayment `json:"lump_sums"`     // One-off payments on a given date
+}
+
+// Structure to represent the result of a prepayment simulation
+type Simulation struct {
+	LoanID         string          `json:"loan_id"`
+	LoanName       string          `json:"loan_name"`
+	Plan           PrepaymentPlan  `json:"plan"`
+	Baseline       domain.Schedule `json:"baseline"`        // Schedule paying only the monthly payment
+	WithPrepayment domain.Schedule `json:"with_prepayment"` // Schedule paying the plan too
+	BaselinePayoff time.Time       `json:"baseline_payoff"`
+	NewPayoff      time.Time       `json:"new_payoff"`
+	MonthsSaved    int             `json:"months_saved"`
+	InterestSaved  domain.Money    `json:"interest_saved"`
+}
+
+// SimulatePrepayments compares the schedule of the loan with the one it would have with the
+// extra payments of the plan, starting at the given date. The loan is not changed.
+func SimulatePrepayments(loan domain.Loan, plan PrepaymentPlan, from time.Time) (Simulation, error) {
+	simulation := Simulation{LoanID: loan.LoanID, LoanName: loan.LoanName, Plan: plan}
+
+	if plan.MonthlyExtra < 0 {
+		return simulation, fmt.Errorf("%w: the monthly extra payment cannot be negative, got %s", ErrInvalidAmount, plan.MonthlyExtra)
+	}
+	for _, lumpSum := range plan.LumpSums {
+		if lumpSum.Amount <= 0 {
+			return simulation, fmt.Errorf("%w: the lump sum of %s must be greater than zero", ErrInvalidAmount, lumpSum.Date.Format(time.DateOnly))
+		}
+	}
+
+	baseline, err := loan.AmortizationSchedule(from)
+	if err != nil {
+		return simulation, err
+	}
+
+	withPrepayment, err := loan.ScheduleWithPrepayments(from, plan.MonthlyExtra, plan.LumpSums)
+	if err != nil {
+		return simulation, err
+	}
+
+	simulation.Baseline = baseline
+	simulation.WithPrepayment = withPrepayment
+	simulation.BaselinePayoff = payoffDate(baseline)
+	simulation.NewPayoff = payoffDate(withPrepayment)
+	simulation.MonthsSaved = len(baseline.Periods) - len(withPrepayment.Periods)
+	simulation.InterestSaved = baseline.TotalInterest - withPrepayment.TotalInterest
+
+	return simulation, nil
+}
+
+// payoffDate returns the due date of the last installment of the schedule.
+func payoffDate(schedule domain.Schedule) time.Time {
+	if len(schedule.Periods) == 0 {
+		return time.Time{}
+	}
+	return schedule.Periods[len(schedule.Periods)-1].DueDate
+}
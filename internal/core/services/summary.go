@@ -0,0 +1,44 @@
+package services
+
+import (
+	"github.com/zapisanchez/loanMgr/internal/core/domain"
+)
+
+// Structure to represent the totals of a set of loans
+type Summary struct {
+	Loans           int                       `json:"loans"`            // Number of loans
+	ByStatus        map[domain.LoanStatus]int `json:"by_status"`        // Number of loans in each state
+	Amount          domain.Money              `json:"amount"`           // Sum of the initial amounts
+	RemainingAmount domain.Money              `json:"remaining_amount"` // Sum of the balances still owed
+	TotalPaid       domain.Money              `json:"total_paid"`       // Sum of every payment
+	InterestPaid    domain.Money              `json:"interest_paid"`    // Part of the total paid that went to interest
+	Credit          domain.Money              `json:"credit"`           // Sum of the credits to refund
+	MonthlyPayment  domain.Money              `json:"monthly_payment"`  // Sum of the monthly payments of the active loans
+}
+
+// Summarize totals the given loans.
+func Summarize(loans []domain.Loan) Summary {
+	summary := Summary{
+		Loans:    len(loans),
+		ByStatus: make(map[domain.LoanStatus]int),
+	}
+
+	for _, loan := range loans {
+		status := loan.GetStatus()
+		summary.ByStatus[status]++
+
+		statement := loan.Replay()
+		summary.Amount += loan.Amount
+		summary.TotalPaid += statement.TotalPaid
+		summary.InterestPaid += statement.InterestPaid
+		summary.Credit += statement.Credit
+
+		// Only the active loans still have something to pay
+		if status == domain.StatusActive {
+			summary.RemainingAmount += statement.Balance
+			summary.MonthlyPayment += loan.CurrentMonthlyPayment()
+		}
+	}
+
+	return summary
+}
@@ -1,7 +1,8 @@
 package services
 
 import (
-	"errors"
+	"fmt"
+	"time"
 
 	"github.com/zapisanchez/loanMgr/internal/core/domain"
 )
@@ -31,7 +32,7 @@ func (s *UserService) CreateUser(userName string) (*domain.User, error) {
 	// return err if user already exists
 	usr := s.repo.GetUser(userName)
 	if usr != nil {
-		return nil, errors.New("user already exists")
+		return nil, fmt.Errorf("%w: %q", ErrUserExists, userName)
 	}
 
 	user := domain.NewUser(userName)
@@ -49,68 +50,199 @@ func (s *UserService) GetUser(userName string) *domain.User {
 }
 
 func (s *UserService) DeleteUser(userName string) error {
-
-	// return err if user already exists
-	usr := s.repo.GetUser(userName)
-	if usr == nil {
-		return errors.New("user not found")
+	if _, err := s.lookupUser(userName); err != nil {
+		return err
 	}
 
-	// return err if user already exists
-	err := s.repo.MoveUserToDeleted(userName)
-	if err == nil {
-		return errors.New("user not found")
-	}
+	return s.repo.MoveUserToDeleted(userName)
+}
 
-	err = s.repo.MoveUserToDeleted(userName)
+func (s *UserService) AddLoanToUser(userName string, loan domain.Loan) error {
+	user, err := s.lookupUser(userName)
 	if err != nil {
 		return err
 	}
+
+	if err := loan.Validate(); err != nil {
+		return err
+	}
+
+	// Never store two loans with the same ID
+	if loan.LoanID == "" || user.GetLoan(loan.LoanID) != nil {
+		loan.LoanID = user.NewLoanID()
+	}
+
+	user.AddLoan(loan)
 	return nil
 }
 
-func (s *UserService) AddLoanToUser(userName string, loan domain.Loan) error {
-	user := s.repo.GetUser(userName)
-	if user == nil {
-		return errors.New("user not found")
+// CreateLoan validates the terms and fees of a new loan and creates it for the user with a new unique loan ID.
+func (s *UserService) CreateLoan(userName, loanName string, amount domain.Money, interest float64, monthlyPayment domain.Money, fees ...domain.Fee) (*domain.Loan, error) {
+	user, err := s.lookupUser(userName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := domain.ValidateLoan(loanName, amount, interest, monthlyPayment); err != nil {
+		return nil, err
+	}
+	for _, fee := range fees {
+		if err := domain.ValidateFee(fee); err != nil {
+			return nil, err
+		}
+	}
+
+	loan := domain.NewLoan(user.NewLoanID(), loanName, amount, interest, monthlyPayment)
+	for _, fee := range fees {
+		_ = loan.AddFee(fee) // Already validated
 	}
 	user.AddLoan(loan)
+
+	return user.GetLoan(loan.LoanID), nil
+}
+
+// AddPaymentToLoan adds a payment to a loan and returns the stored payment. A payment
+// greater than what the loan owes is rejected with ErrOverpayment, capped at the payoff
+// amount or recorded with the excess as a credit, as the overpayment mode says.
+func (s *UserService) AddPaymentToLoan(userName string, loanID string, payment domain.Transaction, mode domain.OverpaymentMode) (domain.Transaction, error) {
+	selectedLoan, err := s.lookupLoan(userName, loanID)
+	if err != nil {
+		return payment, err
+	}
+
+	if status := selectedLoan.GetStatus(); !status.IsOpen() {
+		return payment, fmt.Errorf("%w: loan %s is %s, reopen it to add payments", ErrLoanNotOpen, loanID, status)
+	}
+
+	added, err := selectedLoan.AddPayment(payment, mode)
+	if err != nil {
+		return added, err
+	}
+
+	// A late payment may owe charges
+	applyPenalties(selectedLoan, time.Now())
+	return *selectedLoan.GetPayment(added.ID), nil
+}
+
+// AddTransactionToLoan adds a fee, an adjustment or a refund of the credit to the ledger of a
+// loan and returns the stored entry.
+func (s *UserService) AddTransactionToLoan(userName string, loanID string, transaction domain.Transaction) (domain.Transaction, error) {
+	selectedLoan, err := s.lookupLoan(userName, loanID)
+	if err != nil {
+		return transaction, err
+	}
+
+	if status := selectedLoan.GetStatus(); !status.IsOpen() {
+		return transaction, fmt.Errorf("%w: loan %s is %s, reopen it to change its ledger", ErrLoanNotOpen, loanID, status)
+	}
+
+	added, err := selectedLoan.AddTransaction(transaction)
+	if err != nil {
+		return added, err
+	}
+
+	// A fee or an adjustment changes how much is owed, but not which installments are paid
+	applyPenalties(selectedLoan, time.Now())
+	return added, nil
+}
+
+// PayoffAmount returns the payment that pays off the loan at the given date.
+func (s *UserService) PayoffAmount(userName string, loanID string, dateTime string) (domain.Money, error) {
+	selectedLoan, err := s.lookupLoan(userName, loanID)
+	if err != nil {
+		return 0, err
+	}
+
+	return selectedLoan.PayoffAmount(dateTime), nil
+}
+
+// ModifyPaymentFromLoan changes the amount, description and date of a payment, fee, adjustment
+// or refund, an empty date keeps the current one. The charges of late installments follow the
+// corrected payment.
+func (s *UserService) ModifyPaymentFromLoan(userName string, loanID string, paymentID string, newAmount domain.Money, newDescription string, newDateTime string) error {
+	selectedLoan, err := s.lookupLoan(userName, loanID)
+	if err != nil {
+		return err
+	}
+
+	if status := selectedLoan.GetStatus(); !status.IsOpen() {
+		return fmt.Errorf("%w: loan %s is %s, reopen it to modify payments", ErrLoanNotOpen, loanID, status)
+	}
+
+	if err := selectedLoan.ModifyPayment(paymentID, newAmount, newDescription, newDateTime); err != nil {
+		return err
+	}
+
+	applyPenalties(selectedLoan, time.Now())
 	return nil
 }
 
-func (s *UserService) AddPaymentToLoan(userName string, loanID string, payment domain.Payment) error {
-	user := s.repo.GetUser(userName)
-	if user == nil {
-		return errors.New("user not found")
+// RemovePaymentFromLoan removes a payment, fee, adjustment or refund entered by mistake. The
+// charges of late installments follow the payments left.
+func (s *UserService) RemovePaymentFromLoan(userName string, loanID string, paymentID string) error {
+	selectedLoan, err := s.lookupLoan(userName, loanID)
+	if err != nil {
+		return err
 	}
 
-	selectedLoan := user.GetLoan(loanID)
-	if selectedLoan == nil {
-		return errors.New("loan not found")
+	if status := selectedLoan.GetStatus(); !status.IsOpen() {
+		return fmt.Errorf("%w: loan %s is %s, reopen it to remove payments", ErrLoanNotOpen, loanID, status)
 	}
 
-	if selectedLoan.RemainingAmount == 0 {
-		return errors.New("loan fully paid")
+	if err := selectedLoan.RemovePayment(paymentID); err != nil {
+		return err
 	}
 
-	selectedLoan.AddPayment(payment)
+	applyPenalties(selectedLoan, time.Now())
+	return nil
+}
 
+// ChangeLoanStatus moves a loan to a new state, only through the allowed transitions.
+func (s *UserService) ChangeLoanStatus(userName string, loanID string, status domain.LoanStatus) error {
+	selectedLoan, err := s.lookupLoan(userName, loanID)
+	if err != nil {
+		return err
+	}
+
+	if !selectedLoan.CanTransition(status) {
+		return fmt.Errorf("%w: a loan that is %s cannot be set to %s", ErrInvalidTransition, selectedLoan.GetStatus(), status)
+	}
+
+	selectedLoan.SetStatus(status)
 	return nil
 }
 
-func (s *UserService) ModifyPaymentFromLoan(userName string, loanID string, paymentDate string, newAmount float64, newDescription string) error {
+// ArchiveLoan hides a finished loan from the loan list.
+func (s *UserService) ArchiveLoan(userName string, loanID string) error {
+	return s.ChangeLoanStatus(userName, loanID, domain.StatusArchived)
+}
+
+// ReopenLoan makes a closed, written off or archived loan active again.
+func (s *UserService) ReopenLoan(userName string, loanID string) error {
+	return s.ChangeLoanStatus(userName, loanID, domain.StatusActive)
+}
+
+// lookupUser returns the user with the given name or ErrUserNotFound.
+func (s *UserService) lookupUser(userName string) (*domain.User, error) {
 	user := s.repo.GetUser(userName)
 	if user == nil {
-		return errors.New("user not found")
+		return nil, fmt.Errorf("%w: %q", ErrUserNotFound, userName)
 	}
+	return user, nil
+}
 
-	selectedLoan := user.GetLoan(loanID)
-	if selectedLoan == nil {
-		return errors.New("loan not found")
+// lookupLoan returns the loan of the user with the given ID, or ErrUserNotFound or ErrLoanNotFound.
+func (s *UserService) lookupLoan(userName string, loanID string) (*domain.Loan, error) {
+	user, err := s.lookupUser(userName)
+	if err != nil {
+		return nil, err
 	}
 
-	selectedLoan.ModifyPayment(paymentDate, newAmount, newDescription)
-	return nil
+	loan := user.GetLoan(loanID)
+	if loan == nil {
+		return nil, fmt.Errorf("%w: %q", ErrLoanNotFound, loanID)
+	}
+	return loan, nil
 }
 
 func (s *UserService) Persist() error {